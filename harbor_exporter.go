@@ -14,8 +14,10 @@
 package main
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -49,6 +51,17 @@ const (
 	metricsGroupReplication   = "replication"
 	metricsGroupSystemInfo    = "systeminfo"
 	metricsGroupArtifactsInfo = "artifacts"
+	metricsGroupSecurityHub   = "securityhub"
+	metricsGroupGC            = "gc"
+	metricsGroupAuditLogs     = "auditlogs"
+	metricsGroupJobService    = "jobservice"
+	metricsGroupProjects      = "projects"
+
+	//These are opt-in metricsGroup enum values
+	metricsGroupMembers    = "members"
+	metricsGroupRegistries = "registries"
+	metricsGroupRobots     = "robots"
+	metricsGroupRetention  = "retention"
 )
 
 func metricsGroupValues() []string {
@@ -61,6 +74,22 @@ func metricsGroupValues() []string {
 		metricsGroupReplication,
 		metricsGroupSystemInfo,
 		metricsGroupArtifactsInfo,
+		metricsGroupSecurityHub,
+		metricsGroupGC,
+		metricsGroupAuditLogs,
+		metricsGroupJobService,
+		metricsGroupProjects,
+	}
+}
+
+// optInMetricsGroupValues are the metrics groups only collected when
+// requested with collect.metrics, because they are expensive or sensitive.
+func optInMetricsGroupValues() []string {
+	return []string{
+		metricsGroupMembers,
+		metricsGroupRegistries,
+		metricsGroupRobots,
+		metricsGroupRetention,
 	}
 }
 
@@ -71,20 +100,63 @@ var (
 	componentLabelNames                       = []string{"component"}
 	typeLabelNames                            = []string{"type"}
 	quotaLabelNames                           = []string{"type", "repo_name", "repo_id"}
+	projectQuotaLabelNames                    = []string{"project_name", "project_id"}
 	repoLabelNames                            = []string{"repo_name", "repo_id"}
+	repoPullsLabelNames                       = []string{"project_name", "repo_name"}
 	artifactLabelNames                        = []string{"project_name", "project_id", "repo_name", "repo_id", "artifact_name", "artifact_id", "tag"}
 	artifactVulnerabilitiesLabelNames         = []string{"project_name", "project_id", "repo_name", "repo_id", "artifact_name", "artifact_id", "report_id", "status", "tag"}
 	artifactsVulnerabilitiesScansLabelNames   = []string{"project_name", "project_id", "repo_name", "repo_id", "artifact_name", "artifact_id", "tag"}
 	artifactVulnerabilitiesDurationLabelNames = []string{"project_name", "project_id", "repo_name", "repo_id", "artifact_name", "artifact_id", "report_id", "tag"}
+	artifactsNotPulledLabelNames              = []string{"project_name", "project_id", "repo_name", "repo_id", "days"}
+	artifactsRepoLabelNames                   = []string{"project_name", "project_id", "repo_name", "repo_id"}
+	artifactAccessoryLabelNames               = []string{"project_name", "project_id", "repo_name", "repo_id", "artifact_name", "artifact_id", "type"}
+	artifactsProjectLabelNames                = []string{"project_name", "project_id"}
+	artifactsTypeLabelNames                   = []string{"project_name", "project_id", "type", "media_type"}
+	artifactsPlatformLabelNames               = []string{"project_name", "project_id", "os", "architecture", "multi_arch"}
 	storageLabelNames                         = []string{"storage"}
-	replicationLabelNames                     = []string{"repl_pol_name", "repl_trigger_type"}
-	replicationTaskLabelNames                 = []string{"repl_pol_name", "repl_trigger_type", "result"}
+	replicationLabelNames                     = []string{"repl_pol_name", "repl_trigger_type", "enabled"}
+	replicationTaskLabelNames                 = []string{"repl_pol_name", "repl_trigger_type", "enabled", "result"}
+	replicationExecutionsLabelNames           = []string{"repl_pol_name", "repl_trigger_type", "enabled", "status"}
+	replicationPolicyInfoLabelNames           = []string{"repl_pol_name", "repl_pol_id", "repl_trigger_type", "enabled", "src_registry", "dest_registry", "dest_namespace", "filters", "deletion", "override", "cron"}
+	replicationFailedTaskLabelNames           = []string{"repl_pol_name", "execution_id", "resource_type", "src_resource", "dst_resource", "operation"}
 	systemInfoLabelNames                      = []string{"auth_mode", "project_creation_restriction", "harbor_version", "registry_storage_provider_name"}
+	gcDeletedLabelNames                       = []string{"type"}
+	gcScheduleLabelNames                      = []string{"type", "cron"}
+	auditOperationsLabelNames                 = []string{"project", "operation", "resource_type"}
+	auditUserOperationsLabelNames             = []string{"username", "operation"}
+	jobServiceQueueLabelNames                 = []string{"job_type"}
+	jobServicePoolLabelNames                  = []string{"pool_id", "host"}
+	jobServiceWorkersLabelNames               = []string{"pool_id", "host", "state"}
+	robotLabelNames                           = []string{"name", "level", "project"}
+	robotLevelLabelNames                      = []string{"level"}
+	registryEndpointLabelNames                = []string{"name", "type", "url"}
+	retentionLabelNames                       = []string{"project_name", "project_id", "dry_run"}
+	retentionArtifactsLabelNames              = []string{"project_name", "project_id", "repo_name", "dry_run", "result"}
+	projectLabelNames                         = []string{"project_name", "project_id"}
+	projectRoleLabelNames                     = []string{"project_name", "project_id", "role"}
+	projectPreventVulLabelNames               = []string{"project_name", "project_id", "severity"}
+	projectContentTrustLabelNames             = []string{"project_name", "project_id", "type"}
+	projectProxyCacheLabelNames               = []string{"project_name", "project_id", "registry_id"}
+	projectMembersLabelNames                  = []string{"project_name", "project_id", "role", "entity_type"}
+	projectMemberInfoLabelNames               = []string{"project_name", "project_id", "entity_name", "entity_type", "role"}
+	securityHubVulnerabilitiesLabelNames      = []string{"status"}
+	securityHubDangerousCVELabelNames         = []string{"cve_id", "severity", "package", "version"}
+	securityHubDangerousArtifactLabelNames    = []string{"project_id", "repo_name", "artifact_name", "status"}
+)
+
+var (
+	// errNotFound is returned by fetch when Harbor answers with 404, usually
+	// because the endpoint does not exist in the running Harbor version.
+	errNotFound = errors.New("endpoint not found")
+	// errStopPaging can be returned by a requestAll callback to stop reading
+	// further pages without reporting an error.
+	errStopPaging = errors.New("stop paging")
 )
 
 type metricInfo struct {
-	Desc *prometheus.Desc
-	Type prometheus.ValueType
+	Desc   *prometheus.Desc
+	Type   prometheus.ValueType
+	Labels []string
 }
 
 func newMetricInfo(instanceName string, metricName string, docString string, t prometheus.ValueType, variableLabels []string, constLabels prometheus.Labels) metricInfo {
@@ -95,7 +167,8 @@ func newMetricInfo(instanceName string, metricName string, docString string, t p
 			variableLabels,
 			constLabels,
 		),
-		Type: t,
+		Type:   t,
+		Labels: variableLabels,
 	}
 }
 
@@ -117,18 +190,41 @@ func createMetrics(instanceName string) {
 	allMetrics["statistics_latency"] = newMetricInfo(instanceName, "statistics_latency", "Time in seconds to collect statistics metrics", prometheus.GaugeValue, nil, nil)
 	allMetrics["quotas_count_total"] = newMetricInfo(instanceName, "quotas_count_total", "quotas", prometheus.GaugeValue, quotaLabelNames, nil)
 	allMetrics["quotas_size_bytes"] = newMetricInfo(instanceName, "quotas_size_bytes", "quotas", prometheus.GaugeValue, quotaLabelNames, nil)
+	allMetrics["project_quota_used_bytes"] = newMetricInfo(instanceName, "project_quota_used_bytes", "Storage used by the project in bytes", prometheus.GaugeValue, projectQuotaLabelNames, nil)
+	allMetrics["project_quota_hard_bytes"] = newMetricInfo(instanceName, "project_quota_hard_bytes", "Storage quota of the project in bytes, absent when unlimited", prometheus.GaugeValue, projectQuotaLabelNames, nil)
+	allMetrics["project_quota_unlimited"] = newMetricInfo(instanceName, "project_quota_unlimited", "If the storage quota of the project is unlimited", prometheus.GaugeValue, projectQuotaLabelNames, nil)
+	allMetrics["project_quota_usage_ratio"] = newMetricInfo(instanceName, "project_quota_usage_ratio", "Ratio of the storage quota of the project in use, absent when unlimited", prometheus.GaugeValue, projectQuotaLabelNames, nil)
 	allMetrics["quotas_latency"] = newMetricInfo(instanceName, "quotas_latency", "Time in seconds to collect quota metrics", prometheus.GaugeValue, nil, nil)
 	allMetrics["system_volumes_bytes"] = newMetricInfo(instanceName, "system_volumes_bytes", "Get system volume info (total/free size).", prometheus.GaugeValue, storageLabelNames, nil)
 	allMetrics["system_volumes_latency"] = newMetricInfo(instanceName, "system_volumes_latency", "Time in seconds to collect system_volume metrics", prometheus.GaugeValue, nil, nil)
 	allMetrics["repositories_pull_total"] = newMetricInfo(instanceName, "repositories_pull_total", "Get public repositories which are accessed most.).", prometheus.GaugeValue, repoLabelNames, nil)
 	allMetrics["repositories_star_total"] = newMetricInfo(instanceName, "repositories_star_total", "Get public repositories which are accessed most.).", prometheus.GaugeValue, repoLabelNames, nil)
 	allMetrics["repositories_tags_total"] = newMetricInfo(instanceName, "repositories_tags_total", "Get public repositories which are accessed most.).", prometheus.GaugeValue, repoLabelNames, nil)
+	allMetrics["repository_pulls_total"] = newMetricInfo(instanceName, "repository_pulls_total", "Number of pulls of the repository, including the pulls before it was deleted and created again", prometheus.CounterValue, repoPullsLabelNames, nil)
+	allMetrics["repository_pulls_start_timestamp_seconds"] = newMetricInfo(instanceName, "repository_pulls_start_timestamp_seconds", "Unix timestamp the pull counter of the repository starts from", prometheus.GaugeValue, repoPullsLabelNames, nil)
 	allMetrics["repositories_latency"] = newMetricInfo(instanceName, "repositories_latency", "Time in seconds to collect repository metrics", prometheus.GaugeValue, nil, nil)
 	allMetrics["artifacts_size"] = newMetricInfo(instanceName, "artifacts_size", "Size in bytes for uploaded artifacts", prometheus.GaugeValue, artifactLabelNames, nil)
 	allMetrics["artifacts_vulnerabilities"] = newMetricInfo(instanceName, "artifacts_vulnerabilities", "Detected vulnerabilities for uploaded artifacts", prometheus.GaugeValue, artifactVulnerabilitiesLabelNames, nil)
 	allMetrics["artifacts_vulnerabilities_scan_start"] = newMetricInfo(instanceName, "artifacts_vulnerabilities_scan_start", "Vulnerabilities scan start time", prometheus.GaugeValue, artifactVulnerabilitiesDurationLabelNames, nil)
 	allMetrics["artifacts_vulnerabilities_scan_duration"] = newMetricInfo(instanceName, "artifacts_vulnerabilities_scan_duration", "Vulnerabilities scan duration", prometheus.GaugeValue, artifactVulnerabilitiesDurationLabelNames, nil)
 	allMetrics["artifacts_vulnerabilities_scans"] = newMetricInfo(instanceName, "artifacts_vulnerabilities_scans", "Vulnerabilities scan operation status. Success == 1, running == 2; others == 0", prometheus.CounterValue, artifactsVulnerabilitiesScansLabelNames, nil)
+	allMetrics["artifact_push_timestamp_seconds"] = newMetricInfo(instanceName, "artifact_push_timestamp_seconds", "Unix timestamp of the last push of the artifact", prometheus.GaugeValue, artifactLabelNames, nil)
+	allMetrics["artifact_pull_timestamp_seconds"] = newMetricInfo(instanceName, "artifact_pull_timestamp_seconds", "Unix timestamp of the last pull of the artifact", prometheus.GaugeValue, artifactLabelNames, nil)
+	allMetrics["artifacts_not_pulled"] = newMetricInfo(instanceName, "artifacts_not_pulled", "Number of artifacts in the repository not pulled (or pushed) for more than the given number of days", prometheus.GaugeValue, artifactsNotPulledLabelNames, nil)
+	allMetrics["artifacts_type_count"] = newMetricInfo(instanceName, "artifacts_type_count", "Number of artifacts in the project by artifact type and manifest media type", prometheus.GaugeValue, artifactsTypeLabelNames, nil)
+	allMetrics["artifacts_type_size_bytes"] = newMetricInfo(instanceName, "artifacts_type_size_bytes", "Size in bytes of the artifacts in the project by artifact type and manifest media type", prometheus.GaugeValue, artifactsTypeLabelNames, nil)
+	allMetrics["artifacts_platform_count"] = newMetricInfo(instanceName, "artifacts_platform_count", "Number of image manifests in the project by OS and architecture, multi_arch is true for children of image indexes", prometheus.GaugeValue, artifactsPlatformLabelNames, nil)
+	allMetrics["artifacts_tag_immutable"] = newMetricInfo(instanceName, "artifacts_tag_immutable", "If the tag is protected by an immutability rule", prometheus.GaugeValue, artifactLabelNames, nil)
+	allMetrics["artifacts_tag_signed"] = newMetricInfo(instanceName, "artifacts_tag_signed", "If the tag is signed with notary or the artifact has a cosign/notation signature", prometheus.GaugeValue, artifactLabelNames, nil)
+	allMetrics["artifacts_tags_unsigned"] = newMetricInfo(instanceName, "artifacts_tags_unsigned", "Number of unsigned tags in the repository", prometheus.GaugeValue, artifactsRepoLabelNames, nil)
+	allMetrics["artifacts_tags_mutable"] = newMetricInfo(instanceName, "artifacts_tags_mutable", "Number of tags in the repository not protected by an immutability rule", prometheus.GaugeValue, artifactsRepoLabelNames, nil)
+	allMetrics["artifacts_accessories"] = newMetricInfo(instanceName, "artifacts_accessories", "Number of accessories (signatures, SBOMs, ...) attached to the artifact by type (Harbor 2.5+)", prometheus.GaugeValue, artifactAccessoryLabelNames, nil)
+	allMetrics["artifacts_sbom_status"] = newMetricInfo(instanceName, "artifacts_sbom_status", "SBOM generation status. Success == 1, running == 2; others == 0", prometheus.GaugeValue, artifactVulnerabilitiesDurationLabelNames, nil)
+	allMetrics["artifacts_sbom_start"] = newMetricInfo(instanceName, "artifacts_sbom_start", "SBOM generation start time", prometheus.GaugeValue, artifactVulnerabilitiesDurationLabelNames, nil)
+	allMetrics["artifacts_sbom_duration"] = newMetricInfo(instanceName, "artifacts_sbom_duration", "SBOM generation duration", prometheus.GaugeValue, artifactVulnerabilitiesDurationLabelNames, nil)
+	allMetrics["artifacts_without_sbom"] = newMetricInfo(instanceName, "artifacts_without_sbom", "Number of artifacts in the project without a successfully generated SBOM", prometheus.GaugeValue, artifactsProjectLabelNames, nil)
+	allMetrics["artifacts_listed_timestamp_seconds"] = newMetricInfo(instanceName, "artifacts_listed_timestamp_seconds", "Unix timestamp of the last listing of the artifacts of the repository the artifact metrics come from", prometheus.GaugeValue, artifactsRepoLabelNames, nil)
+	allMetrics["artifacts_project_listed_timestamp_seconds"] = newMetricInfo(instanceName, "artifacts_project_listed_timestamp_seconds", "Unix timestamp of the oldest listing of the artifacts of the project's repositories the project artifact totals come from", prometheus.GaugeValue, artifactsProjectLabelNames, nil)
 	allMetrics["artifacts_latency"] = newMetricInfo(instanceName, "artifacts_latency", "Time in seconds to collect artifacts metrics", prometheus.GaugeValue, nil, nil)
 	allMetrics["replication_status"] = newMetricInfo(instanceName, "replication_status", "Get status of the last execution of this replication policy: Succeed = 1, any other status = 0.", prometheus.GaugeValue, replicationLabelNames, nil)
 	allMetrics["replication_tasks"] = newMetricInfo(instanceName, "replication_tasks", "Get number of replication tasks, with various results, in the latest execution of this replication policy.", prometheus.GaugeValue, replicationTaskLabelNames, nil)
@@ -139,7 +235,67 @@ func createMetrics(instanceName string) {
 	allMetrics["system_read_only"] = newMetricInfo(instanceName, "system_read_only", "If harbor is in read-only mode", prometheus.GaugeValue, nil, nil)
 	allMetrics["system_with_chartmuseum"] = newMetricInfo(instanceName, "system_with_chartmuseum", "If harbor has chartmuseum enabled", prometheus.GaugeValue, nil, nil)
 	allMetrics["system_notification_enable"] = newMetricInfo(instanceName, "system_notification_enable", "If notifications are enabled", prometheus.GaugeValue, nil, nil)
+	allMetrics["replication_last_success_timestamp_seconds"] = newMetricInfo(instanceName, "replication_last_success_timestamp_seconds", "Unix timestamp of the end of the last successful execution of this replication policy.", prometheus.GaugeValue, replicationLabelNames, nil)
+	allMetrics["replication_last_duration_seconds"] = newMetricInfo(instanceName, "replication_last_duration_seconds", "Duration in seconds of the last finished execution of this replication policy.", prometheus.GaugeValue, replicationLabelNames, nil)
+	allMetrics["replication_executions"] = newMetricInfo(instanceName, "replication_executions", "Get number of executions of this replication policy, by status, started within the replication.window.", prometheus.GaugeValue, replicationExecutionsLabelNames, nil)
+	allMetrics["replication_failed_task"] = newMetricInfo(instanceName, "replication_failed_task", "A metric with a constant '1' value for each failed task in the latest execution of this replication policy.", prometheus.GaugeValue, replicationFailedTaskLabelNames, nil)
+	allMetrics["replication_policy_info"] = newMetricInfo(instanceName, "replication_policy_info", "A metric with a constant '1' value labeled by the configuration of this replication policy.", prometheus.GaugeValue, replicationPolicyInfoLabelNames, nil)
 	allMetrics["replication_latency"] = newMetricInfo(instanceName, "replication_latency", "Time in seconds to collect replication metrics", prometheus.GaugeValue, nil, nil)
+	allMetrics["gc_status"] = newMetricInfo(instanceName, "gc_status", "Get status of the last garbage collection: Success = 1, any other status = 0.", prometheus.GaugeValue, nil, nil)
+	allMetrics["gc_start_timestamp_seconds"] = newMetricInfo(instanceName, "gc_start_timestamp_seconds", "Unix timestamp of the start of the last garbage collection", prometheus.GaugeValue, nil, nil)
+	allMetrics["gc_end_timestamp_seconds"] = newMetricInfo(instanceName, "gc_end_timestamp_seconds", "Unix timestamp of the end of the last garbage collection", prometheus.GaugeValue, nil, nil)
+	allMetrics["gc_duration_seconds"] = newMetricInfo(instanceName, "gc_duration_seconds", "Duration in seconds of the last garbage collection", prometheus.GaugeValue, nil, nil)
+	allMetrics["gc_deleted"] = newMetricInfo(instanceName, "gc_deleted", "Number of blobs and manifests deleted by the last garbage collection", prometheus.GaugeValue, gcDeletedLabelNames, nil)
+	allMetrics["gc_freed_bytes"] = newMetricInfo(instanceName, "gc_freed_bytes", "Bytes freed by the last garbage collection", prometheus.GaugeValue, nil, nil)
+	allMetrics["gc_schedule_info"] = newMetricInfo(instanceName, "gc_schedule_info", "A metric with a constant '1' value labeled by the type and cron of the garbage collection schedule", prometheus.GaugeValue, gcScheduleLabelNames, nil)
+	allMetrics["gc_next_scheduled_timestamp_seconds"] = newMetricInfo(instanceName, "gc_next_scheduled_timestamp_seconds", "Unix timestamp of the next scheduled garbage collection", prometheus.GaugeValue, nil, nil)
+	allMetrics["gc_latency"] = newMetricInfo(instanceName, "gc_latency", "Time in seconds to collect garbage collection metrics", prometheus.GaugeValue, nil, nil)
+	allMetrics["audit_operations_total"] = newMetricInfo(instanceName, "audit_operations_total", "Number of operations recorded in the audit log since the exporter started", prometheus.CounterValue, auditOperationsLabelNames, nil)
+	allMetrics["audit_user_operations_total"] = newMetricInfo(instanceName, "audit_user_operations_total", "Number of operations recorded in the audit log since the exporter started for the most active users and robots", prometheus.CounterValue, auditUserOperationsLabelNames, nil)
+	allMetrics["audit_last_id"] = newMetricInfo(instanceName, "audit_last_id", "ID of the last audit log entry read", prometheus.GaugeValue, nil, nil)
+	allMetrics["audit_latency"] = newMetricInfo(instanceName, "audit_latency", "Time in seconds to collect audit log metrics", prometheus.GaugeValue, nil, nil)
+	allMetrics["jobservice_queue_length"] = newMetricInfo(instanceName, "jobservice_queue_length", "Number of pending jobs in the jobservice queue", prometheus.GaugeValue, jobServiceQueueLabelNames, nil)
+	allMetrics["jobservice_queue_latency_seconds"] = newMetricInfo(instanceName, "jobservice_queue_latency_seconds", "Time in seconds the oldest job has been waiting in the jobservice queue", prometheus.GaugeValue, jobServiceQueueLabelNames, nil)
+	allMetrics["jobservice_queue_paused"] = newMetricInfo(instanceName, "jobservice_queue_paused", "If the jobservice queue is paused", prometheus.GaugeValue, jobServiceQueueLabelNames, nil)
+	allMetrics["jobservice_pool_concurrency"] = newMetricInfo(instanceName, "jobservice_pool_concurrency", "Number of workers of the jobservice pool", prometheus.GaugeValue, jobServicePoolLabelNames, nil)
+	allMetrics["jobservice_pool_workers"] = newMetricInfo(instanceName, "jobservice_pool_workers", "Number of busy and idle workers of the jobservice pool", prometheus.GaugeValue, jobServiceWorkersLabelNames, nil)
+	allMetrics["jobservice_latency"] = newMetricInfo(instanceName, "jobservice_latency", "Time in seconds to collect jobservice metrics", prometheus.GaugeValue, nil, nil)
+	allMetrics["robot_expiry_timestamp_seconds"] = newMetricInfo(instanceName, "robot_expiry_timestamp_seconds", "Unix timestamp at which the robot account expires", prometheus.GaugeValue, robotLabelNames, nil)
+	allMetrics["robot_disabled"] = newMetricInfo(instanceName, "robot_disabled", "If the robot account is disabled", prometheus.GaugeValue, robotLabelNames, nil)
+	allMetrics["robots_never_expiring"] = newMetricInfo(instanceName, "robots_never_expiring", "Number of robot accounts that never expire", prometheus.GaugeValue, robotLevelLabelNames, nil)
+	allMetrics["robots_latency"] = newMetricInfo(instanceName, "robots_latency", "Time in seconds to collect robot account metrics", prometheus.GaugeValue, nil, nil)
+	allMetrics["registry_endpoint_up"] = newMetricInfo(instanceName, "registry_endpoint_up", "Was the last ping of the replication endpoint successful.", prometheus.GaugeValue, registryEndpointLabelNames, nil)
+	allMetrics["registry_endpoint_ping_latency"] = newMetricInfo(instanceName, "registry_endpoint_ping_latency", "Time in seconds to ping the replication endpoint", prometheus.GaugeValue, registryEndpointLabelNames, nil)
+	allMetrics["registries_latency"] = newMetricInfo(instanceName, "registries_latency", "Time in seconds to collect registry endpoint metrics", prometheus.GaugeValue, nil, nil)
+	allMetrics["retention_status"] = newMetricInfo(instanceName, "retention_status", "Get status of the last execution of the tag retention policy of the project: Success = 1, any other status = 0.", prometheus.GaugeValue, retentionLabelNames, nil)
+	allMetrics["retention_timestamp_seconds"] = newMetricInfo(instanceName, "retention_timestamp_seconds", "Unix timestamp of the end of the last execution of the tag retention policy of the project", prometheus.GaugeValue, retentionLabelNames, nil)
+	allMetrics["retention_artifacts"] = newMetricInfo(instanceName, "retention_artifacts", "Number of artifacts retained and deleted per repository by the last execution of the tag retention policy", prometheus.GaugeValue, retentionArtifactsLabelNames, nil)
+	allMetrics["retention_latency"] = newMetricInfo(instanceName, "retention_latency", "Time in seconds to collect tag retention metrics", prometheus.GaugeValue, nil, nil)
+	allMetrics["project_public"] = newMetricInfo(instanceName, "project_public", "If the project is public", prometheus.GaugeValue, projectLabelNames, nil)
+	allMetrics["project_auto_scan"] = newMetricInfo(instanceName, "project_auto_scan", "If images are scanned automatically when pushed to the project", prometheus.GaugeValue, projectLabelNames, nil)
+	allMetrics["project_prevent_vul"] = newMetricInfo(instanceName, "project_prevent_vul", "If images with vulnerabilities of the given severity or higher are prevented from being pulled", prometheus.GaugeValue, projectPreventVulLabelNames, nil)
+	allMetrics["project_content_trust"] = newMetricInfo(instanceName, "project_content_trust", "If only signed images can be pulled from the project", prometheus.GaugeValue, projectContentTrustLabelNames, nil)
+	allMetrics["project_reuse_sys_cve_allowlist"] = newMetricInfo(instanceName, "project_reuse_sys_cve_allowlist", "If the project uses the system CVE allowlist", prometheus.GaugeValue, projectLabelNames, nil)
+	allMetrics["project_proxy_cache"] = newMetricInfo(instanceName, "project_proxy_cache", "If the project is a proxy cache of the given registry endpoint", prometheus.GaugeValue, projectProxyCacheLabelNames, nil)
+	allMetrics["project_cve_allowlist_items"] = newMetricInfo(instanceName, "project_cve_allowlist_items", "Number of CVEs in the CVE allowlist of the project", prometheus.GaugeValue, projectLabelNames, nil)
+	allMetrics["project_cve_allowlist_expiry_timestamp_seconds"] = newMetricInfo(instanceName, "project_cve_allowlist_expiry_timestamp_seconds", "Unix timestamp at which the CVE allowlist of the project expires, absent if it never expires", prometheus.GaugeValue, projectLabelNames, nil)
+	allMetrics["system_cve_allowlist_items"] = newMetricInfo(instanceName, "system_cve_allowlist_items", "Number of CVEs in the system CVE allowlist", prometheus.GaugeValue, nil, nil)
+	allMetrics["system_cve_allowlist_expiry_timestamp_seconds"] = newMetricInfo(instanceName, "system_cve_allowlist_expiry_timestamp_seconds", "Unix timestamp at which the system CVE allowlist expires, absent if it never expires", prometheus.GaugeValue, nil, nil)
+	allMetrics["project_repo_count"] = newMetricInfo(instanceName, "project_repo_count", "Number of repositories in the project", prometheus.GaugeValue, projectLabelNames, nil)
+	allMetrics["project_chart_count"] = newMetricInfo(instanceName, "project_chart_count", "Number of charts in the project chartmuseum", prometheus.GaugeValue, projectLabelNames, nil)
+	allMetrics["project_storage_used_bytes"] = newMetricInfo(instanceName, "project_storage_used_bytes", "Storage used by the project in bytes, from the project summary", prometheus.GaugeValue, projectLabelNames, nil)
+	allMetrics["project_storage_hard_bytes"] = newMetricInfo(instanceName, "project_storage_hard_bytes", "Storage quota of the project in bytes from the project summary, absent if unlimited", prometheus.GaugeValue, projectLabelNames, nil)
+	allMetrics["project_members_by_role"] = newMetricInfo(instanceName, "project_members_by_role", "Number of members of the project by role", prometheus.GaugeValue, projectRoleLabelNames, nil)
+	allMetrics["projects_latency"] = newMetricInfo(instanceName, "projects_latency", "Time in seconds to collect project metrics", prometheus.GaugeValue, nil, nil)
+	allMetrics["project_members"] = newMetricInfo(instanceName, "project_members", "Number of members of the project by role and entity type", prometheus.GaugeValue, projectMembersLabelNames, nil)
+	allMetrics["project_member_info"] = newMetricInfo(instanceName, "project_member_info", "A metric with a constant '1' value for each member of the project", prometheus.GaugeValue, projectMemberInfoLabelNames, nil)
+	allMetrics["members_latency"] = newMetricInfo(instanceName, "members_latency", "Time in seconds to collect project member metrics", prometheus.GaugeValue, nil, nil)
+	allMetrics["securityhub_vulnerabilities"] = newMetricInfo(instanceName, "securityhub_vulnerabilities", "Registry-wide number of vulnerabilities reported by the security hub", prometheus.GaugeValue, securityHubVulnerabilitiesLabelNames, nil)
+	allMetrics["securityhub_artifacts"] = newMetricInfo(instanceName, "securityhub_artifacts", "Registry-wide number of artifacts (total/scanned) reported by the security hub", prometheus.GaugeValue, typeLabelNames, nil)
+	allMetrics["securityhub_dangerous_cve_score"] = newMetricInfo(instanceName, "securityhub_dangerous_cve_score", "CVSS v3 score of the most dangerous CVEs reported by the security hub", prometheus.GaugeValue, securityHubDangerousCVELabelNames, nil)
+	allMetrics["securityhub_dangerous_cve_records"] = newMetricInfo(instanceName, "securityhub_dangerous_cve_records", "Number of vulnerability records, one per affected artifact, of the most dangerous CVEs reported by the security hub in the package, absent above 1000", prometheus.GaugeValue, securityHubDangerousCVELabelNames, nil)
+	allMetrics["securityhub_dangerous_artifact_vulnerabilities"] = newMetricInfo(instanceName, "securityhub_dangerous_artifact_vulnerabilities", "Vulnerabilities of the most dangerous artifacts reported by the security hub", prometheus.GaugeValue, securityHubDangerousArtifactLabelNames, nil)
+	allMetrics["securityhub_latency"] = newMetricInfo(instanceName, "securityhub_latency", "Time in seconds to collect security hub metrics", prometheus.GaugeValue, nil, nil)
 }
 
 type promHTTPLogger struct {
@@ -164,12 +320,46 @@ type HarborExporter struct {
 	isV2     bool
 	pageSize int
 	client   *http.Client
+	etags    etagCache
+	// Concurrent requests when fetching the pages of a paginated endpoint
+	pageConcurrency int
+	// Quotas-related
+	quotasLegacy bool
+	// Members-related
+	membersInfo bool
+	// Projects-related
+	projectsSummary bool
+	// Repositories-related
+	repositoriesListing       string
+	repositoriesPullsStart    bool
+	pullCounters              pullCounters
+	globalRepositoriesMissing int32
+	// Artifacts-related
+	staleDays            []int
+	artifactsIncremental bool
+	artifactsMaxAge      time.Duration
+	artifactsRolling     int
+	artifactCache        artifactCache
+	// Replication-related
+	replicationWindow      time.Duration
+	replicationFailedTasks int
+	// Audit logs-related
+	auditLogs         auditLogState
+	auditLogsMaxPages int
+	auditLogsTopUsers int
 	// Cache-related
 	cacheEnabled    bool
 	cacheDuration   time.Duration
 	lastCollectTime time.Time
 	cache           []prometheus.Metric
 	collectMutex    sync.Mutex
+	// State-related
+	storagePath       string
+	storageInterval   time.Duration
+	stateMutex        sync.Mutex
+	snapshotTime      time.Time
+	snapshot          []stateSample
+	artifactsSaveTime time.Time
 }
 
 // NewHarborExporter constructs a HarborExporter instance
@@ -208,41 +398,156 @@ func (h *HarborExporter) request(endpoint string) ([]byte, error) {
 	return body, err
 }
 
+// requestAll fetches every page of endpoint and hands them to callback in
+// page order. Once the first page reports the total with x-total-count, the
+// remaining pages are fetched concurrently. Without it, rel="next" links of
+// the Link header are followed one by one.
 func (h *HarborExporter) requestAll(endpoint string, callback func([]byte) error) error {
-	page := 1
 	separator := "?"
 	if strings.Index(endpoint, separator) > 0 {
 		separator = "&"
 	}
-	for {
-		path := fmt.Sprintf("%s%spage=%d&page_size=%d", endpoint, separator, page, h.pageSize)
-		body, headers, err := h.fetch(path)
-		if err != nil {
-			return err
+	pagePath := func(page int) string {
+		return fmt.Sprintf("%s%spage=%d&page_size=%d", endpoint, separator, page, h.pageSize)
+	}
+
+	body, headers, err := h.fetch(pagePath(1))
+	if err != nil {
+		return err
+	}
+	err = callback(body)
+	if err == errStopPaging {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	countStr := headers.Get("x-total-count")
+	if countStr == "" {
+		return h.requestLinks(headers, callback)
+	}
+
+	count, err := strconv.Atoi(countStr)
+	if err != nil {
+		return err
+	}
+
+	pages := (count + h.pageSize - 1) / h.pageSize
+	if pages <= 1 {
+		return nil
+	}
+	return h.requestPages(pagePath, 2, pages, callback)
+}
+
+// requestPages fetches the pages from first to last with at most
+// pageConcurrency of them in flight, and hands them to callback in order.
+func (h *HarborExporter) requestPages(pagePath func(int) string, first int, last int, callback func([]byte) error) error {
+	type pageResult struct {
+		body []byte
+		err  error
+	}
+
+	concurrency := h.pageConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]chan pageResult, last-first+1)
+	for i := range results {
+		results[i] = make(chan pageResult, 1)
+	}
+	slots := make(chan struct{}, concurrency)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for i := range results {
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+			go func(i int) {
+				body, _, err := h.fetch(pagePath(first + i))
+				results[i] <- pageResult{body, err}
+			}(i)
 		}
+	}()
 
-		err = callback(body)
+	for i := range results {
+		r := <-results[i]
+		<-slots
+		if r.err != nil {
+			return r.err
+		}
+		err := callback(r.body)
+		if err == errStopPaging {
+			return nil
+		}
 		if err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		countStr := headers.Get("x-total-count")
-		if countStr == "" {
-			break
+// requestLinks follows the rel="next" links of the Link header.
+func (h *HarborExporter) requestLinks(headers http.Header, callback func([]byte) error) error {
+	for {
+		next := nextLink(headers)
+		if next == "" {
+			return nil
 		}
+		// Links are absolute paths including the API prefix.
+		next = strings.TrimPrefix(next, h.uri)
+		next = strings.TrimPrefix(next, h.apiPath)
 
-		count, err := strconv.Atoi(countStr)
+		var (
+			body []byte
+			err  error
+		)
+		body, headers, err = h.fetch(next)
 		if err != nil {
 			return err
 		}
-
-		if page*h.pageSize >= count {
-			break
+		err = callback(body)
+		if err == errStopPaging {
+			return nil
+		}
+		if err != nil {
+			return err
 		}
+	}
+}
 
-		page++
+// nextLink returns the target of the rel="next" link of a Link header, such
+// as `</api/v2.0/projects?page=2&page_size=10>; rel="next"`.
+func nextLink(headers http.Header) string {
+	for _, header := range headers["Link"] {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			if len(parts) < 2 {
+				continue
+			}
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				param = strings.Replace(strings.TrimSpace(param), " ", "", -1)
+				if param == `rel="next"` || param == "rel=next" {
+					return target[1 : len(target)-1]
+				}
+			}
+		}
 	}
-	return nil
+	return ""
 }
 
 func (h *HarborExporter) fetch(endpoint string) ([]byte, http.Header, error) {
@@ -253,6 +558,16 @@ func (h *HarborExporter) fetch(endpoint string) ([]byte, http.Header, error) {
 		return nil, nil, err
 	}
 	req.SetBasicAuth(h.username, h.password)
+	// Only artifact listings are requested conditionally, and only when they
+	// are listed incrementally: the cache holds a copy of every response.
+	cacheable := h.artifactsIncremental && strings.Contains(endpoint, "/artifacts?")
+	var cached *etagEntry
+	if cacheable {
+		cached = h.etags.get(endpoint)
+	}
+	if cached != nil {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
 
 	resp, err := h.client.Do(req)
 	if err != nil {
@@ -261,9 +576,17 @@ func (h *HarborExporter) fetch(endpoint string) ([]byte, http.Header, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		level.Debug(h.logger).Log("msg", "Not modified since last request for "+endpoint)
+		return cached.body, cached.headers, nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		level.Debug(h.logger).Log("msg", "Endpoint not found for "+endpoint, "http-statuscode", resp.Status)
+		return nil, nil, errNotFound
+	}
 	if resp.StatusCode != http.StatusOK {
 		level.Error(h.logger).Log("msg", "Error handling request for "+endpoint, "http-statuscode", resp.Status)
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("unexpected status %s for %s", resp.Status, endpoint)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -271,9 +594,85 @@ func (h *HarborExporter) fetch(endpoint string) ([]byte, http.Header, error) {
 		level.Error(h.logger).Log("msg", "Error reading response of request for "+endpoint, "err", err.Error())
 		return nil, nil, err
 	}
+	if etag := resp.Header.Get("ETag"); cacheable && etag != "" {
+		h.etags.put(endpoint, &etagEntry{etag: etag, body: body, headers: resp.Header})
+	}
 	return body, resp.Header, nil
 }
 
+// etagEntry is the last response of an endpoint served with an ETag, returned
+// again when Harbor answers a conditional request with 304 Not Modified.
+type etagEntry struct {
+	etag    string
+	body    []byte
+	headers http.Header
+	used    bool
+}
+
+// etagCache holds the responses of the endpoints that support ETags. Entries
+// not requested during a collection are dropped by prune.
+type etagCache struct {
+	mutex   sync.Mutex
+	entries map[string]*etagEntry
+}
+
+func (c *etagCache) get(endpoint string) *etagEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry := c.entries[endpoint]
+	if entry != nil {
+		entry.used = true
+	}
+	return entry
+}
+
+func (c *etagCache) put(endpoint string, entry *etagEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*etagEntry)
+	}
+	entry.used = true
+	c.entries[endpoint] = entry
+}
+
+func (c *etagCache) prune() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for endpoint, entry := range c.entries {
+		if !entry.used {
+			delete(c.entries, endpoint)
+			continue
+		}
+		entry.used = false
+	}
+}
+
+func (h *HarborExporter) post(endpoint string, payload interface{}) error {
+	level.Debug(h.logger).Log("endpoint", endpoint, "method", "POST")
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", h.uri+h.apiPath+endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(h.username, h.password)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s for %s", resp.Status, endpoint)
+	}
+	return nil
+}
+
 func checkHarborVersion(h *HarborExporter) error {
 	resp, err := h.client.Get(h.uri + "/api/systeminfo")
 	if err == nil {
@@ -340,6 +739,7 @@ func (h *HarborExporter) Collect(outCh chan<- prometheus.Metric) {
 	samplesCh := make(chan prometheus.Metric)
 	// Use WaitGroup to ensure outCh isn't closed before the goroutine is finished
 	var wg sync.WaitGroup
+	var collected []prometheus.Metric
 	wg.Add(1)
 	go func() {
 		for metric := range samplesCh {
@@ -347,6 +747,9 @@ func (h *HarborExporter) Collect(outCh chan<- prometheus.Metric) {
 			if h.cacheEnabled {
 				h.cache = append(h.cache, metric)
 			}
+			if h.storagePath != "" {
+				collected = append(collected, metric)
+			}
 		}
 		wg.Done()
 	}()
@@ -379,6 +782,33 @@ func (h *HarborExporter) Collect(outCh chan<- prometheus.Metric) {
 	if collectMetricsGroup[metricsGroupArtifactsInfo] {
 		ok = h.collectArtifactsMetric(samplesCh) && ok
 	}
+	if collectMetricsGroup[metricsGroupSecurityHub] {
+		ok = h.collectSecurityHubMetric(samplesCh) && ok
+	}
+	if collectMetricsGroup[metricsGroupGC] {
+		ok = h.collectGCMetric(samplesCh) && ok
+	}
+	if collectMetricsGroup[metricsGroupAuditLogs] {
+		ok = h.collectAuditLogsMetric(samplesCh) && ok
+	}
+	if collectMetricsGroup[metricsGroupJobService] {
+		ok = h.collectJobServiceMetric(samplesCh) && ok
+	}
+	if collectMetricsGroup[metricsGroupRobots] {
+		ok = h.collectRobotsMetric(samplesCh) && ok
+	}
+	if collectMetricsGroup[metricsGroupRegistries] {
+		ok = h.collectRegistriesMetric(samplesCh) && ok
+	}
+	if collectMetricsGroup[metricsGroupRetention] {
+		ok = h.collectRetentionMetric(samplesCh) && ok
+	}
+	if collectMetricsGroup[metricsGroupProjects] {
+		ok = h.collectProjectsMetric(samplesCh) && ok
+	}
+	if collectMetricsGroup[metricsGroupMembers] {
+		ok = h.collectMembersMetric(samplesCh) && ok
+	}
 
 	if ok {
 		samplesCh <- prometheus.MustNewConstMetric(
@@ -393,8 +823,20 @@ func (h *HarborExporter) Collect(outCh chan<- prometheus.Metric) {
 	}
 
 	close(samplesCh)
+	h.etags.prune()
 	h.lastCollectTime = time.Now()
 	wg.Wait()
+
+	// A partly failed collection would overwrite the last good snapshot, the
+	// cursors and counters it moved are saved regardless.
+	if h.storagePath != "" {
+		if !ok {
+			collected = nil
+		}
+		if err := h.saveState(collected); err != nil {
+			level.Error(h.logger).Log("msg", "Error saving state to "+h.storagePath, "err", err.Error())
+		}
+	}
 }
 
 // Status2i converts health status to int8
@@ -427,8 +869,25 @@ func main() {
 	kingpin.Flag("harbor.timeout", "Timeout on HTTP requests to the harbor API.").Default("500ms").DurationVar(&exporter.timeout)
 	kingpin.Flag("harbor.insecure", "Disable TLS host verification.").Default("false").BoolVar(&exporter.insecure)
 	kingpin.Flag("harbor.pagesize", "Page size on requests to the harbor API.").Envar("HARBOR_PAGESIZE").Default("100").IntVar(&exporter.pageSize)
+	kingpin.Flag("repositories.listing", "How repositories are listed: auto uses the global listing of Harbor 2.1+ when available, global always uses it, per-project lists the repositories of each project.").Default(repositoriesListingAuto).EnumVar(&exporter.repositoriesListing, repositoriesListingValues()...)
+	kingpin.Flag("repositories.pulls-start", "Export harbor_repository_pulls_start_timestamp_seconds, the time harbor_repository_pulls_total counts from.").Default("false").BoolVar(&exporter.repositoriesPullsStart)
+	kingpin.Flag("quotas.legacy", "Also export the legacy quotas_count_total and quotas_size_bytes metrics on Harbor 2.x.").Envar("HARBOR_QUOTAS_LEGACY").Default("false").BoolVar(&exporter.quotasLegacy)
+	kingpin.Flag("artifacts.stale-days", "Report artifacts not pulled for more than this many days. Can be repeated.").Default("30", "90", "180").IntsVar(&exporter.staleDays)
+	kingpin.Flag("artifacts.incremental", "Only list again the artifacts of repositories that changed since the previous collection.").Envar("HARBOR_ARTIFACTS_INCREMENTAL").Default("false").BoolVar(&exporter.artifactsIncremental)
+	kingpin.Flag("artifacts.max-age", "With artifacts.incremental, list the artifacts of unchanged repositories again after this long.").Default("1h").DurationVar(&exporter.artifactsMaxAge)
+	kingpin.Flag("artifacts.rolling", "List the artifacts of at most this many repositories per collection in round-robin order and serve the others from the previous listings, 0 lists all of them.").Envar("HARBOR_ARTIFACTS_ROLLING").Default("0").IntVar(&exporter.artifactsRolling)
+	kingpin.Flag("auditlogs.max-pages", "Maximum number of audit log pages read per collection.").Default("50").IntVar(&exporter.auditLogsMaxPages)
+	kingpin.Flag("auditlogs.top-users", "Export per-user operation counters for the N most active users and robots, 0 disables them.").Default("0").IntVar(&exporter.auditLogsTopUsers)
+	kingpin.Flag("replication.window", "Sliding window over which replication executions are counted.").Default("24h").DurationVar(&exporter.replicationWindow)
+	kingpin.Flag("replication.failed-tasks", "Report up to this many failed tasks of the latest execution of each replication policy, 0 disables it.").Default("0").IntVar(&exporter.replicationFailedTasks)
+	kingpin.Flag("harbor.page-concurrency", "Maximum number of pages fetched concurrently from paginated harbor API endpoints.").Envar("HARBOR_PAGE_CONCURRENCY").Default("4").IntVar(&exporter.pageConcurrency)
 	skip := kingpin.Flag("skip.metrics", "Skip these metrics groups").Enums(metricsGroupValues()...)
+	collect := kingpin.Flag("collect.metrics", "Collect these opt-in metrics groups").Enums(optInMetricsGroupValues()...)
+	kingpin.Flag("projects.summary", "Read the summary of every project in the projects group, one request per project.").Default("false").BoolVar(&exporter.projectsSummary)
+	kingpin.Flag("members.info", "Export one series per project member in the members group.").Default("false").BoolVar(&exporter.membersInfo)
 	kingpin.Flag("cache.enabled", "Enable metrics caching.").Envar("HARBOR_CACHE_ENABLED").Default("false").BoolVar(&exporter.cacheEnabled)
+	kingpin.Flag("storage.path", "Directory to keep the last metrics, artifact listings, pull counters and audit log cursor in across restarts. The last metrics are only served after a restart with cache.enabled.").Envar("HARBOR_STORAGE_PATH").Default("").StringVar(&exporter.storagePath)
+	kingpin.Flag("storage.interval", "Minimum time between two writes of the artifact listings under storage.path.").Envar("HARBOR_STORAGE_INTERVAL").Default("5m").DurationVar(&exporter.storageInterval)
 	kingpin.Flag("cache.duration", "Time duration collected values are cached for.").Envar("HARBOR_CACHE_DURATION").Default("20s").DurationVar(&exporter.cacheDuration)
 
 	promlogConfig := &promlog.Config{}
@@ -437,6 +896,23 @@ func main() {
 	kingpin.Parse()
 	logger := promlog.New(promlogConfig)
 
+	// Repeated thresholds would export duplicate series and fail the scrape.
+	var (
+		staleDays = make([]int, 0, len(exporter.staleDays))
+		seenDays  = make(map[int]bool)
+	)
+	for _, days := range exporter.staleDays {
+		if days <= 0 {
+			level.Error(logger).Log("msg", "artifacts.stale-days must be positive", "value", days)
+			os.Exit(1)
+		}
+		if !seenDays[days] {
+			seenDays[days] = true
+			staleDays = append(staleDays, days)
+		}
+	}
+	exporter.staleDays = staleDays
+
 	client, err := getHTTPClient(exporter.insecure)
 	if err != nil {
 		level.Error(logger).Log("msg", "Failed to create HTTP client")
@@ -454,10 +930,17 @@ func main() {
 	for _, v := range metricsGroupValues() {
 		collectMetricsGroup[v] = true
 	}
+	for _, v := range optInMetricsGroupValues() {
+		collectMetricsGroup[v] = false
+	}
 	for _, v := range *skip {
 		level.Debug(logger).Log("skip", v)
 		collectMetricsGroup[v] = false
 	}
+	for _, v := range *collect {
+		level.Debug(logger).Log("collect", v)
+		collectMetricsGroup[v] = true
+	}
 	for k, v := range collectMetricsGroup {
 		level.Info(logger).Log("metrics_group", k, "collect", v)
 	}
@@ -472,6 +955,12 @@ func main() {
 
 	createMetrics(exporter.instance)
 
+	if exporter.storagePath != "" {
+		if err := exporter.loadState(); err != nil {
+			level.Error(logger).Log("msg", "Error loading state from "+exporter.storagePath, "err", err)
+		}
+	}
+
 	prometheus.MustRegister(exporter)
 	prometheus.MustRegister(version.NewCollector("harbor_exporter"))
 
@@ -3,17 +3,46 @@ package main
 import (
 	"encoding/json"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// projectMetadata holds the project settings, Harbor returns all values as
+// strings.
+type projectMetadata struct {
+	AutoScan                 string `json:"auto_scan,omitempty"`
+	EnableContentTrust       string `json:"enable_content_trust,omitempty"`
+	EnableContentTrustCosign string `json:"enable_content_trust_cosign,omitempty"`
+	PreventVul               string `json:"prevent_vul,omitempty"`
+	Public                   string `json:"public,omitempty"`
+	RetentionID              string `json:"retention_id,omitempty"`
+	ReuseSysCVEAllowlist     string `json:"reuse_sys_cve_allowlist,omitempty"`
+	ReuseSysCVEWhitelist     string `json:"reuse_sys_cve_whitelist,omitempty"`
+	Severity                 string `json:"severity,omitempty"`
+}
+
+// cveAllowlist is the list of CVEs ignored by vulnerability prevention. An
+// expiry of 0 or null means it never expires.
+type cveAllowlist struct {
+	ExpiresAt int64 `json:"expires_at"`
+	Items     []struct {
+		CVEID string `json:"cve_id"`
+	} `json:"items"`
+}
+
 type project struct {
-	ProjectID int64  `json:"project_id,omitempty"`
-	Name      string `json:"name,omitempty"`
+	ProjectID    int64           `json:"project_id,omitempty"`
+	Name         string          `json:"name,omitempty"`
+	CVEAllowlist cveAllowlist    `json:"cve_allowlist,omitempty"`
+	CVEWhitelist *cveAllowlist   `json:"cve_whitelist,omitempty"`
+	Metadata     projectMetadata `json:"metadata,omitempty"`
+	RegistryID   int64           `json:"registry_id,omitempty"`
 
 	repositories repositories
 }
@@ -21,10 +50,16 @@ type project struct {
 type projects []project
 
 type repository struct {
-	ID   int64  `json:"id,omitempty"`
-	Name string `json:"name,omitempty"`
-
-	artifacts artifacts
+	ID            int64     `json:"id,omitempty"`
+	Name          string    `json:"name,omitempty"`
+	ProjectID     int64     `json:"project_id,omitempty"`
+	ArtifactCount int64     `json:"artifact_count,omitempty"`
+	PullCount     int64     `json:"pull_count,omitempty"`
+	CreationTime  time.Time `json:"creation_time,omitempty"`
+	UpdateTime    time.Time `json:"update_time,omitempty"`
+
+	artifacts         artifacts
+	artifactsListTime time.Time
 }
 
 type repositories []repository
@@ -48,14 +83,54 @@ type scanOverview struct {
 	} `json:"summary"`
 }
 
+// sbomOverview is the SBOM generation summary of an artifact (Harbor 2.11+).
+type sbomOverview struct {
+	Duration   int       `json:"duration"`
+	EndTime    time.Time `json:"end_time"`
+	ReportID   string    `json:"report_id"`
+	SBOMDigest string    `json:"sbom_digest"`
+	ScanStatus string    `json:"scan_status"`
+	StartTime  time.Time `json:"start_time"`
+}
+
+// artifactPlatform is the platform of an image, as found in the extra_attrs
+// of an image manifest or in the references of an image index.
+type artifactPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+type artifactReference struct {
+	ChildDigest string           `json:"child_digest"`
+	ChildID     int64            `json:"child_id"`
+	Platform    artifactPlatform `json:"platform"`
+}
+
+// artifactAccessory is an artifact attached to another one, such as a cosign
+// or notation signature or an SBOM (Harbor 2.5+).
+type artifactAccessory struct {
+	Digest string `json:"digest"`
+	ID     int64  `json:"id"`
+	Size   int64  `json:"size"`
+	Type   string `json:"type"`
+}
+
 type artifact struct {
-	Digest       string       `json:"digest"`
-	ID           int64        `json:"id"`
-	ProjectID    int64        `json:"project_id"`
-	RepositoryID int64        `json:"repository_id"`
-	ScanOverview scanOverview `json:"scan_overview"`
-	Size         int64        `json:"size"`
-	Tags         []struct {
+	Accessories       []artifactAccessory `json:"accessories"`
+	Digest            string              `json:"digest"`
+	ExtraAttrs        artifactPlatform    `json:"extra_attrs"`
+	ID                int64               `json:"id"`
+	ManifestMediaType string              `json:"manifest_media_type"`
+	MediaType         string              `json:"media_type"`
+	ProjectID         int64               `json:"project_id"`
+	PullTime          time.Time           `json:"pull_time"`
+	PushTime          time.Time           `json:"push_time"`
+	References        []artifactReference `json:"references"`
+	RepositoryID      int64               `json:"repository_id"`
+	SBOMOverview      sbomOverview        `json:"sbom_overview"`
+	ScanOverview      scanOverview        `json:"scan_overview"`
+	Size              int64               `json:"size"`
+	Tags              []struct {
 		ArtifactID   int64  `json:"artifact_id"`
 		ID           int64  `json:"id"`
 		Immutable    bool   `json:"immutable"`
@@ -68,6 +143,92 @@ type artifact struct {
 
 type artifacts []artifact
 
+// artifactCacheEntry is the artifact listing of a repository along with the
+// repository fields that change when artifacts are pushed, deleted or pulled.
+type artifactCacheEntry struct {
+	updateTime    time.Time
+	artifactCount int64
+	pullCount     int64
+	listTime      time.Time
+	artifacts     artifacts
+}
+
+// artifactCache keeps the artifact listing of every repository between
+// collections, keyed by repository ID. cursor is the last repository listed
+// by the rolling walk.
+type artifactCache struct {
+	mutex  sync.Mutex
+	repos  map[int64]*artifactCacheEntry
+	cursor int64
+}
+
+// get returns the cached listing of repo, if any, and whether it is still
+// fresh: the repository did not change since it was listed and it is not
+// older than maxAge. Scan results and signatures do not update the
+// repository, maxAge bounds their staleness.
+func (c *artifactCache) get(repo *repository, maxAge time.Duration, now time.Time) (*artifactCacheEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry := c.repos[repo.ID]
+	if entry == nil {
+		return nil, false
+	}
+	fresh := entry.updateTime.Equal(repo.UpdateTime) &&
+		entry.artifactCount == repo.ArtifactCount &&
+		entry.pullCount == repo.PullCount &&
+		now.Sub(entry.listTime) <= maxAge
+	return entry, fresh
+}
+
+// next picks up to n of the given repository IDs in round-robin order,
+// starting after the last one picked by the previous call.
+func (c *artifactCache) next(ids []int64, n int) map[int64]bool {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	first := sort.Search(len(ids), func(i int) bool { return ids[i] > c.cursor })
+	picked := make(map[int64]bool, n)
+	for i := 0; i < len(ids) && len(picked) < n; i++ {
+		id := ids[(first+i)%len(ids)]
+		picked[id] = true
+		c.cursor = id
+	}
+	return picked
+}
+
+func (c *artifactCache) put(repo *repository, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.repos == nil {
+		c.repos = make(map[int64]*artifactCacheEntry)
+	}
+	c.repos[repo.ID] = &artifactCacheEntry{
+		updateTime:    repo.UpdateTime,
+		artifactCount: repo.ArtifactCount,
+		pullCount:     repo.PullCount,
+		listTime:      now,
+		artifacts:     repo.artifacts,
+	}
+}
+
+// prune drops the repositories that no longer exist.
+func (c *artifactCache) prune(projectsData projects) {
+	seen := make(map[int64]bool)
+	for i := range projectsData {
+		for _, repo := range projectsData[i].repositories {
+			seen[repo.ID] = true
+		}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for id := range c.repos {
+		if !seen[id] {
+			delete(c.repos, id)
+		}
+	}
+}
+
 func (h *HarborExporter) collectArtifactsMetric(ch chan<- prometheus.Metric) bool {
 	// Do not load V1 API.
 	// ToDo: Implement V1 support.
@@ -96,14 +257,53 @@ func (h *HarborExporter) collectArtifactsMetric(ch chan<- prometheus.Metric) boo
 		scansMI      = allMetrics["artifacts_vulnerabilities_scans"]
 		scansDurMI   = allMetrics["artifacts_vulnerabilities_scan_duration"]
 		scansStartTS = allMetrics["artifacts_vulnerabilities_scan_start"]
+		pushTS       = allMetrics["artifact_push_timestamp_seconds"]
+		pullTS       = allMetrics["artifact_pull_timestamp_seconds"]
+		notPulledMI  = allMetrics["artifacts_not_pulled"]
+		typeCountMI  = allMetrics["artifacts_type_count"]
+		typeSizeMI   = allMetrics["artifacts_type_size_bytes"]
+		platformMI   = allMetrics["artifacts_platform_count"]
+		immutableMI  = allMetrics["artifacts_tag_immutable"]
+		signedMI     = allMetrics["artifacts_tag_signed"]
+		unsignedMI   = allMetrics["artifacts_tags_unsigned"]
+		mutableMI    = allMetrics["artifacts_tags_mutable"]
+		accessoryMI  = allMetrics["artifacts_accessories"]
+		sbomMI       = allMetrics["artifacts_sbom_status"]
+		sbomStartTS  = allMetrics["artifacts_sbom_start"]
+		sbomDurMI    = allMetrics["artifacts_sbom_duration"]
+		noSBOMMI     = allMetrics["artifacts_without_sbom"]
+		listedTS     = allMetrics["artifacts_listed_timestamp_seconds"]
+		projListTS   = allMetrics["artifacts_project_listed_timestamp_seconds"]
 	)
 
+	type typeKey struct {
+		artType   string
+		mediaType string
+	}
+	type typeStats struct {
+		count int
+		size  int64
+	}
+	type platformKey struct {
+		artifactPlatform
+		multiArch bool
+	}
+
 	for pi := range prData {
 		var (
 			pp = &prData[pi]
 
 			projectName = pp.Name
 			projectID   = strconv.FormatInt(pp.ProjectID, 10)
+
+			types     = make(map[typeKey]*typeStats)
+			platforms = make(map[platformKey]int)
+			noSBOM    int
+
+			// Oldest listing of the project's repositories, the age of the
+			// project totals.
+			oldestListing time.Time
+			complete      = true
 		)
 
 		for ri := range pp.repositories {
@@ -112,6 +312,10 @@ func (h *HarborExporter) collectArtifactsMetric(ch chan<- prometheus.Metric) boo
 
 				repoName = rp.Name
 				repoID   = strconv.FormatInt(rp.ID, 10)
+
+				notPulled = make([]int, len(h.staleDays))
+				unsigned  int
+				mutable   int
 			)
 
 			for ai := range rp.artifacts {
@@ -121,15 +325,102 @@ func (h *HarborExporter) collectArtifactsMetric(ch chan<- prometheus.Metric) boo
 					artID   = strconv.FormatInt(ap.ID, 10)
 					artName = ap.Digest
 				)
+
+				// Type and platform breakdown. Image indexes contribute the
+				// platforms of their children, plain images their own.
+				tk := typeKey{artType: strings.ToLower(ap.Type), mediaType: ap.ManifestMediaType}
+				if types[tk] == nil {
+					types[tk] = &typeStats{}
+				}
+				types[tk].count++
+				types[tk].size += ap.Size
+
+				if len(ap.References) > 0 {
+					for _, ref := range ap.References {
+						if ref.Platform.OS != "" {
+							platforms[platformKey{ref.Platform, true}]++
+						}
+					}
+				} else if ap.ExtraAttrs.OS != "" {
+					platforms[platformKey{ap.ExtraAttrs, false}]++
+				}
+
+				// Staleness. An artifact that was never pulled is stale
+				// relative to its push time.
+				lastUsed := ap.PushTime
+				if ap.PullTime.After(lastUsed) {
+					lastUsed = ap.PullTime
+				}
+				for di, days := range h.staleDays {
+					if start.Sub(lastUsed) > time.Duration(days)*24*time.Hour {
+						notPulled[di]++
+					}
+				}
+
+				// SBOM.
+				var sbomInfo = &ap.SBOMOverview
+				if sbomInfo.ReportID == "" || !strings.EqualFold(sbomInfo.ScanStatus, "success") {
+					noSBOM++
+				}
+
+				// Accessories. A cosign or notation signature counts as a
+				// signature for every tag of the artifact.
+				var (
+					accessories  = make(map[string]int)
+					hasSignature bool
+				)
+				for _, acc := range ap.Accessories {
+					accessories[acc.Type]++
+					if strings.HasPrefix(acc.Type, "signature.") {
+						hasSignature = true
+					}
+				}
+				for accType, count := range accessories {
+					ch <- prometheus.MustNewConstMetric(accessoryMI.Desc, accessoryMI.Type, float64(count), projectName, projectID, repoName, repoID, artName, artID, accType)
+				}
+
 				for ti := range ap.Tags {
 					var (
 						tag     = &ap.Tags[ti]
 						tagName = tag.Name
 					)
 
+					// Immutability and signature.
+					signed := tag.Signed || hasSignature
+					if !signed {
+						unsigned++
+					}
+					if !tag.Immutable {
+						mutable++
+					}
+					ch <- prometheus.MustNewConstMetric(immutableMI.Desc, immutableMI.Type, float64(Btoi(tag.Immutable)), projectName, projectID, repoName, repoID, artName, artID, tagName)
+					ch <- prometheus.MustNewConstMetric(signedMI.Desc, signedMI.Type, float64(Btoi(signed)), projectName, projectID, repoName, repoID, artName, artID, tagName)
+
+					// SBOM Status.
+					if sbomInfo.ReportID != "" {
+						var sbomRes float64
+
+						switch strings.ToLower(sbomInfo.ScanStatus) {
+						case "success":
+							sbomRes = 1
+						case "running":
+							sbomRes = 2
+						}
+
+						ch <- prometheus.MustNewConstMetric(sbomMI.Desc, sbomMI.Type, sbomRes, projectName, projectID, repoName, repoID, artName, artID, sbomInfo.ReportID, tagName)
+						ch <- prometheus.MustNewConstMetric(sbomStartTS.Desc, sbomStartTS.Type, float64(sbomInfo.StartTime.Unix()), projectName, projectID, repoName, repoID, artName, artID, sbomInfo.ReportID, tagName)
+						ch <- prometheus.MustNewConstMetric(sbomDurMI.Desc, sbomDurMI.Type, float64(sbomInfo.Duration), projectName, projectID, repoName, repoID, artName, artID, sbomInfo.ReportID, tagName)
+					}
+
 					// Size.
 					ch <- prometheus.MustNewConstMetric(sizeMI.Desc, sizeMI.Type, float64(ap.Size), projectName, projectID, repoName, repoID, artName, artID, tagName)
 
+					// Push and pull times.
+					ch <- prometheus.MustNewConstMetric(pushTS.Desc, pushTS.Type, float64(ap.PushTime.Unix()), projectName, projectID, repoName, repoID, artName, artID, tagName)
+					if !ap.PullTime.IsZero() {
+						ch <- prometheus.MustNewConstMetric(pullTS.Desc, pullTS.Type, float64(ap.PullTime.Unix()), projectName, projectID, repoName, repoID, artName, artID, tagName)
+					}
+
 					// Vulnerabilities.
 					var scanInfo = &ap.ScanOverview
 
@@ -163,6 +454,40 @@ func (h *HarborExporter) collectArtifactsMetric(ch chan<- prometheus.Metric) boo
 					ch <- prometheus.MustNewConstMetric(scansMI.Desc, scansMI.Type, scanRes, projectName, projectID, repoName, repoID, artName, artID, tagName)
 				}
 			}
+
+			if rp.artifactsListTime.IsZero() {
+				// Rolling walk did not reach the repository yet.
+				complete = false
+				continue
+			}
+			if oldestListing.IsZero() || rp.artifactsListTime.Before(oldestListing) {
+				oldestListing = rp.artifactsListTime
+			}
+			ch <- prometheus.MustNewConstMetric(listedTS.Desc, listedTS.Type, float64(rp.artifactsListTime.Unix()), projectName, projectID, repoName, repoID)
+			ch <- prometheus.MustNewConstMetric(unsignedMI.Desc, unsignedMI.Type, float64(unsigned), projectName, projectID, repoName, repoID)
+			ch <- prometheus.MustNewConstMetric(mutableMI.Desc, mutableMI.Type, float64(mutable), projectName, projectID, repoName, repoID)
+
+			for di, days := range h.staleDays {
+				ch <- prometheus.MustNewConstMetric(notPulledMI.Desc, notPulledMI.Type, float64(notPulled[di]), projectName, projectID, repoName, repoID, strconv.Itoa(days))
+			}
+		}
+
+		// Project totals would leave out the repositories the rolling walk
+		// did not reach yet.
+		if !complete {
+			continue
+		}
+		if !oldestListing.IsZero() {
+			ch <- prometheus.MustNewConstMetric(projListTS.Desc, projListTS.Type, float64(oldestListing.Unix()), projectName, projectID)
+		}
+		ch <- prometheus.MustNewConstMetric(noSBOMMI.Desc, noSBOMMI.Type, float64(noSBOM), projectName, projectID)
+
+		for tk, ts := range types {
+			ch <- prometheus.MustNewConstMetric(typeCountMI.Desc, typeCountMI.Type, float64(ts.count), projectName, projectID, tk.artType, tk.mediaType)
+			ch <- prometheus.MustNewConstMetric(typeSizeMI.Desc, typeSizeMI.Type, float64(ts.size), projectName, projectID, tk.artType, tk.mediaType)
+		}
+		for pk, count := range platforms {
+			ch <- prometheus.MustNewConstMetric(platformMI.Desc, platformMI.Type, float64(count), projectName, projectID, pk.OS, pk.Architecture, strconv.FormatBool(pk.multiArch))
 		}
 	}
 
@@ -196,32 +521,35 @@ func (h *HarborExporter) loadProjects() (projects, error) {
 
 func (h *HarborExporter) loadRepositories(projectsData projects) (projects, error) {
 	// Load Repositories for Projects.
-	for i := range projectsData {
-		projectID := strconv.FormatInt(projectsData[i].ProjectID, 10)
-
-		var reqURL string
-		if h.isV2 {
-			reqURL = "/projects/" + projectsData[i].Name + "/repositories"
-		} else {
-			reqURL = "/repositories?project_id=" + projectID
-		}
+	reposData, err := h.loadProjectRepositories(projectsData)
+	if err != nil {
+		return nil, err
+	}
 
-		var pageData repositories
-		err := h.requestAll(reqURL, func(pageBody []byte) error {
-			if err := json.Unmarshal(pageBody, &pageData); err != nil {
-				return err
+	// In rolling mode, only a slice of the repositories that need it are
+	// listed again, the others are served from the cache.
+	var refresh map[int64]bool
+	if h.artifactsRolling > 0 {
+		var (
+			ids []int64
+			now = time.Now()
+		)
+		for _, data := range reposData {
+			for ri := range data {
+				if h.artifactsIncremental {
+					if _, fresh := h.artifactCache.get(&data[ri], h.artifactsMaxAge, now); fresh {
+						continue
+					}
+				}
+				ids = append(ids, data[ri].ID)
 			}
-
-			return nil
-		})
-		if err != nil {
-			level.Error(h.logger).Log(err.Error())
-
-			return nil, err
 		}
+		refresh = h.artifactCache.next(ids, h.artifactsRolling)
+	}
 
+	for i := range projectsData {
 		// Load Artifacts for Repositories.
-		af, err := h.loadArtifacts(projectsData[i].Name, pageData)
+		af, err := h.loadArtifacts(projectsData[i].Name, reposData[projectsData[i].ProjectID], refresh)
 		if err != nil {
 			level.Error(h.logger).Log(err.Error())
 
@@ -232,18 +560,32 @@ func (h *HarborExporter) loadRepositories(projectsData projects) (projects, erro
 		projectsData[i].repositories = af
 	}
 
+	if h.artifactsIncremental || h.artifactsRolling > 0 {
+		h.artifactCache.prune(projectsData)
+	}
+
 	return projectsData, nil
 }
 
-func (h *HarborExporter) loadArtifacts(projectName string, repoData repositories) (repositories, error) {
+// loadArtifacts lists the artifacts of the given repositories. In rolling
+// mode, repositories not in refresh get their cached artifacts, if any.
+func (h *HarborExporter) loadArtifacts(projectName string, repoData repositories, refresh map[int64]bool) (repositories, error) {
 	type rawArtifacts []struct {
-		Digest       string                  `json:"digest"`
-		ID           int64                   `json:"id"`
-		ProjectID    int64                   `json:"project_id"`
-		RepositoryID int64                   `json:"repository_id"`
-		ScanOverview map[string]scanOverview `json:"scan_overview"`
-		Size         int64                   `json:"size"`
-		Tags         []struct {
+		Accessories       []artifactAccessory     `json:"accessories"`
+		Digest            string                  `json:"digest"`
+		ExtraAttrs        artifactPlatform        `json:"extra_attrs"`
+		ID                int64                   `json:"id"`
+		ManifestMediaType string                  `json:"manifest_media_type"`
+		MediaType         string                  `json:"media_type"`
+		ProjectID         int64                   `json:"project_id"`
+		PullTime          time.Time               `json:"pull_time"`
+		PushTime          time.Time               `json:"push_time"`
+		References        []artifactReference     `json:"references"`
+		RepositoryID      int64                   `json:"repository_id"`
+		SBOMOverview      sbomOverview            `json:"sbom_overview"`
+		ScanOverview      map[string]scanOverview `json:"scan_overview"`
+		Size              int64                   `json:"size"`
+		Tags              []struct {
 			ArtifactID   int64  `json:"artifact_id"`
 			ID           int64  `json:"id"`
 			Immutable    bool   `json:"immutable"`
@@ -254,12 +596,26 @@ func (h *HarborExporter) loadArtifacts(projectName string, repoData repositories
 		Type string `json:"type"`
 	}
 
+	now := time.Now()
 	for i := range repoData {
+		if h.artifactsIncremental || h.artifactsRolling > 0 {
+			cached, fresh := h.artifactCache.get(&repoData[i], h.artifactsMaxAge, now)
+			if cached != nil && ((h.artifactsIncremental && fresh) || (h.artifactsRolling > 0 && !refresh[repoData[i].ID])) {
+				repoData[i].artifacts = cached.artifacts
+				repoData[i].artifactsListTime = cached.listTime
+				continue
+			}
+			if cached == nil && h.artifactsRolling > 0 && !refresh[repoData[i].ID] {
+				// Not listed yet, its turn will come.
+				continue
+			}
+		}
+
 		var reqURL string
 		if h.isV2 {
 			reqURL = "/projects/" + projectName +
 				"/repositories/" + url.PathEscape(url.PathEscape(strings.TrimPrefix(repoData[i].Name, projectName+"/"))) +
-				"/artifacts?with_tag=true&with_scan_overview=true"
+				"/artifacts?with_tag=true&with_scan_overview=true&with_signature=true&with_immutable_status=true&with_accessory=true&with_sbom_overview=true"
 		} else {
 			panic("No v1 API support")
 		}
@@ -287,18 +643,26 @@ func (h *HarborExporter) loadArtifacts(projectName string, repoData repositories
 				}
 
 				repoArts = append(repoArts, artifact{
-					Digest:       pp.Digest,
-					ID:           pp.ID,
-					ProjectID:    pp.ProjectID,
-					RepositoryID: pp.RepositoryID,
-					Size:         pp.Size,
-					Tags:         pp.Tags,
-					Type:         pp.Type,
-					ScanOverview: parsedScanOverview,
+					Accessories:       pp.Accessories,
+					Digest:            pp.Digest,
+					ExtraAttrs:        pp.ExtraAttrs,
+					ID:                pp.ID,
+					ManifestMediaType: pp.ManifestMediaType,
+					MediaType:         pp.MediaType,
+					ProjectID:         pp.ProjectID,
+					PullTime:          pp.PullTime,
+					PushTime:          pp.PushTime,
+					References:        pp.References,
+					RepositoryID:      pp.RepositoryID,
+					SBOMOverview:      pp.SBOMOverview,
+					Size:              pp.Size,
+					Tags:              pp.Tags,
+					Type:              pp.Type,
+					ScanOverview:      parsedScanOverview,
 				})
 			}
 
-			repoData[i].artifacts = repoArts
+			repoData[i].artifacts = append(repoData[i].artifacts, repoArts...)
 
 			return nil
 		}); err != nil {
@@ -306,6 +670,11 @@ func (h *HarborExporter) loadArtifacts(projectName string, repoData repositories
 
 			return nil, err
 		}
+
+		repoData[i].artifactsListTime = now
+		if h.artifactsIncremental || h.artifactsRolling > 0 {
+			h.artifactCache.put(&repoData[i], now)
+		}
 	}
 
 	return repoData, nil
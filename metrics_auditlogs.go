@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type auditLogKey struct {
+	project      string
+	operation    string
+	resourceType string
+}
+
+// auditLogState holds the audit log cursor and the counters built from the
+// entries seen since the exporter started, or restored from storage.path.
+// Once tailing, every entry after lastID is counted, even if the audit log
+// was empty when the cursor was set.
+type auditLogState struct {
+	mutex      sync.Mutex
+	loaded     bool
+	tailing    bool
+	lastID     int64
+	operations map[auditLogKey]float64
+	users      map[string]map[string]float64
+}
+
+func (h *HarborExporter) collectAuditLogsMetric(ch chan<- prometheus.Metric) bool {
+	// Do not load V1 API.
+	// ToDo: Implement V1 support.
+	if !h.isV2 {
+		return true
+	}
+
+	start := time.Now()
+
+	type auditLogs []struct {
+		ID           int64     `json:"id"`
+		Username     string    `json:"username"`
+		Resource     string    `json:"resource"`
+		ResourceType string    `json:"resource_type"`
+		Operation    string    `json:"operation"`
+		OpTime       time.Time `json:"op_time"`
+	}
+
+	s := &h.auditLogs
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.loaded {
+		s.loaded = true
+		s.operations = make(map[auditLogKey]float64)
+		s.users = make(map[string]map[string]float64)
+	}
+
+	// Entries are listed newest first: read pages until reaching the cursor.
+	var (
+		newEntries auditLogs
+		newestID   = s.lastID
+		caughtUp   bool
+		// Entries written while paging push older ones to the next page,
+		// which then returns entries already read.
+		seen = make(map[int64]bool)
+	)
+	for page := 1; page <= h.auditLogsMaxPages && !caughtUp; page++ {
+		body, _, err := h.fetch(fmt.Sprintf("/audit-logs?sort=-op_time&page=%d&page_size=%d", page, h.pageSize))
+		if err != nil {
+			level.Error(h.logger).Log("msg", "Error retrieving audit logs", "err", err.Error())
+			return false
+		}
+		var pageData auditLogs
+		if err := json.Unmarshal(body, &pageData); err != nil {
+			level.Error(h.logger).Log(err.Error())
+			return false
+		}
+		if len(pageData) < h.pageSize {
+			caughtUp = true
+		}
+		for _, entry := range pageData {
+			if entry.ID > newestID {
+				newestID = entry.ID
+			}
+			if entry.ID <= s.lastID {
+				caughtUp = true
+				break
+			}
+			if seen[entry.ID] {
+				continue
+			}
+			seen[entry.ID] = true
+			newEntries = append(newEntries, entry)
+		}
+		// Without a cursor, only the newest entry is needed to start tailing.
+		if !s.tailing {
+			caughtUp = true
+		}
+	}
+	if !caughtUp {
+		level.Warn(h.logger).Log("msg", "Audit log backlog exceeds auditlogs.max-pages, some entries were not counted")
+	}
+
+	if s.tailing {
+		for _, entry := range newEntries {
+			project := entry.Resource
+			if i := strings.Index(project, "/"); i >= 0 {
+				project = project[:i]
+			}
+			s.operations[auditLogKey{project, entry.Operation, entry.ResourceType}]++
+
+			if h.auditLogsTopUsers > 0 {
+				if s.users[entry.Username] == nil {
+					s.users[entry.Username] = make(map[string]float64)
+				}
+				s.users[entry.Username][entry.Operation]++
+			}
+		}
+	}
+
+	s.tailing = true
+	s.lastID = newestID
+
+	for k, v := range s.operations {
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["audit_operations_total"].Desc, allMetrics["audit_operations_total"].Type, v, k.project, k.operation, k.resourceType,
+		)
+	}
+
+	// Only the most active users are tracked, the others are dropped so that
+	// the counters do not grow with every user ever seen.
+	if h.auditLogsTopUsers > 0 {
+		type userTotal struct {
+			username string
+			total    float64
+		}
+		var totals []userTotal
+		for username, ops := range s.users {
+			var total float64
+			for _, v := range ops {
+				total += v
+			}
+			totals = append(totals, userTotal{username, total})
+		}
+		sort.Slice(totals, func(i, j int) bool {
+			if totals[i].total == totals[j].total {
+				return totals[i].username < totals[j].username
+			}
+			return totals[i].total > totals[j].total
+		})
+		if len(totals) > h.auditLogsTopUsers {
+			for _, t := range totals[h.auditLogsTopUsers:] {
+				delete(s.users, t.username)
+			}
+		}
+		for username, ops := range s.users {
+			for operation, v := range ops {
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["audit_user_operations_total"].Desc, allMetrics["audit_user_operations_total"].Type, v, username, operation,
+				)
+			}
+		}
+	} else if len(s.users) > 0 {
+		s.users = make(map[string]map[string]float64)
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		allMetrics["audit_last_id"].Desc, allMetrics["audit_last_id"].Type, float64(s.lastID),
+	)
+
+	reportLatency(start, "audit_latency", ch)
+	return true
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// These are the summary lines the GC job writes to its log.
+	gcDeletedRegexp = regexp.MustCompile(`(\d+) blobs and (\d+) manifests are actually deleted`)
+	gcFreedRegexp   = regexp.MustCompile(`The GC job actual frees up (\d+) MB space`)
+)
+
+func (h *HarborExporter) collectGCMetric(ch chan<- prometheus.Metric) bool {
+	// Do not load V1 API.
+	// ToDo: Implement V1 support.
+	if !h.isV2 {
+		return true
+	}
+
+	start := time.Now()
+
+	type gcSchedule struct {
+		Type              string    `json:"type"`
+		Cron              string    `json:"cron"`
+		NextScheduledTime time.Time `json:"next_scheduled_time"`
+	}
+	type gcHistory []struct {
+		ID            int64      `json:"id"`
+		JobStatus     string     `json:"job_status"`
+		JobParameters string     `json:"job_parameters"`
+		Schedule      gcSchedule `json:"schedule"`
+		CreationTime  time.Time  `json:"creation_time"`
+		UpdateTime    time.Time  `json:"update_time"`
+	}
+	type gcParameters struct {
+		DryRun bool `json:"dry_run"`
+	}
+
+	body, err := h.request("/system/gc?page=1&page_size=10&sort=-creation_time")
+	if err != nil {
+		level.Error(h.logger).Log("msg", "Error retrieving GC history", "err", err.Error())
+		return false
+	}
+	var history gcHistory
+	if err := json.Unmarshal(body, &history); err != nil {
+		level.Error(h.logger).Log(err.Error())
+		return false
+	}
+
+	// The last run is the most recent finished job that was not a dry run.
+	var j = -1
+	for i := range history {
+		switch history[i].JobStatus {
+		case "Running", "Pending", "Scheduled":
+			continue
+		}
+		var params gcParameters
+		if history[i].JobParameters != "" {
+			if err := json.Unmarshal([]byte(history[i].JobParameters), &params); err != nil {
+				level.Debug(h.logger).Log("msg", "Error parsing GC job parameters", "err", err.Error())
+			}
+		}
+		if params.DryRun {
+			continue
+		}
+		j = i
+		break
+	}
+
+	if j < 0 {
+		level.Debug(h.logger).Log("msg", "GC has no finished executions yet")
+	} else {
+		last := history[j]
+
+		var gcStatus float64
+		if last.JobStatus == "Success" {
+			gcStatus = 1
+		}
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["gc_status"].Desc, allMetrics["gc_status"].Type, gcStatus,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["gc_start_timestamp_seconds"].Desc, allMetrics["gc_start_timestamp_seconds"].Type, float64(last.CreationTime.Unix()),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["gc_end_timestamp_seconds"].Desc, allMetrics["gc_end_timestamp_seconds"].Type, float64(last.UpdateTime.Unix()),
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["gc_duration_seconds"].Desc, allMetrics["gc_duration_seconds"].Type, last.UpdateTime.Sub(last.CreationTime).Seconds(),
+		)
+
+		// Freed blobs, manifests and bytes are only reported in the job log.
+		gcLog, err := h.request("/system/gc/" + strconv.FormatInt(last.ID, 10) + "/log")
+		if err != nil {
+			level.Debug(h.logger).Log("msg", "Error retrieving GC log", "err", err.Error())
+		} else {
+			if m := gcDeletedRegexp.FindSubmatch(gcLog); m != nil {
+				blobs, _ := strconv.ParseFloat(string(m[1]), 64)
+				manifests, _ := strconv.ParseFloat(string(m[2]), 64)
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["gc_deleted"].Desc, allMetrics["gc_deleted"].Type, blobs, "blob",
+				)
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["gc_deleted"].Desc, allMetrics["gc_deleted"].Type, manifests, "manifest",
+				)
+			}
+			if m := gcFreedRegexp.FindSubmatch(gcLog); m != nil {
+				freedMB, _ := strconv.ParseFloat(string(m[1]), 64)
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["gc_freed_bytes"].Desc, allMetrics["gc_freed_bytes"].Type, freedMB*1024*1024,
+				)
+			}
+		}
+	}
+
+	body, err = h.request("/system/gc/schedule")
+	if err == errNotFound {
+		level.Debug(h.logger).Log("msg", "GC has no schedule")
+	} else if err != nil {
+		level.Error(h.logger).Log("msg", "Error retrieving GC schedule", "err", err.Error())
+		return false
+	} else {
+		var schedule struct {
+			Schedule gcSchedule `json:"schedule"`
+		}
+		if err := json.Unmarshal(body, &schedule); err != nil {
+			level.Error(h.logger).Log(err.Error())
+			return false
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["gc_schedule_info"].Desc, allMetrics["gc_schedule_info"].Type, 1, schedule.Schedule.Type, schedule.Schedule.Cron,
+		)
+		if !schedule.Schedule.NextScheduledTime.IsZero() {
+			ch <- prometheus.MustNewConstMetric(
+				allMetrics["gc_next_scheduled_timestamp_seconds"].Desc, allMetrics["gc_next_scheduled_timestamp_seconds"].Type, float64(schedule.Schedule.NextScheduledTime.Unix()),
+			)
+		}
+	}
+
+	reportLatency(start, "gc_latency", ch)
+	return true
+}
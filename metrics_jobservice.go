@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func (h *HarborExporter) collectJobServiceMetric(ch chan<- prometheus.Metric) bool {
+	// Jobservice monitoring is only available on Harbor 2.7+.
+	if !h.isV2 {
+		return true
+	}
+
+	start := time.Now()
+
+	type queuesMetric []struct {
+		JobType string  `json:"job_type"`
+		Count   float64 `json:"count"`
+		Latency float64 `json:"latency"`
+		Paused  bool    `json:"paused"`
+	}
+	type poolsMetric []struct {
+		WorkerPoolID string  `json:"worker_pool_id"`
+		Host         string  `json:"host"`
+		Concurrency  float64 `json:"concurrency"`
+	}
+	type workersMetric []struct {
+		ID    string `json:"id"`
+		JobID string `json:"job_id"`
+	}
+
+	body, err := h.request("/jobservice/queues")
+	if err == errNotFound {
+		level.Debug(h.logger).Log("msg", "Jobservice monitoring is not available, it requires Harbor 2.7 or later")
+		return true
+	}
+	if err != nil {
+		level.Error(h.logger).Log("msg", "Error retrieving jobservice queues", "err", err.Error())
+		return false
+	}
+	var queues queuesMetric
+	if err := json.Unmarshal(body, &queues); err != nil {
+		level.Error(h.logger).Log(err.Error())
+		return false
+	}
+
+	for _, q := range queues {
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["jobservice_queue_length"].Desc, allMetrics["jobservice_queue_length"].Type, q.Count, q.JobType,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["jobservice_queue_latency_seconds"].Desc, allMetrics["jobservice_queue_latency_seconds"].Type, q.Latency, q.JobType,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["jobservice_queue_paused"].Desc, allMetrics["jobservice_queue_paused"].Type, float64(Btoi(q.Paused)), q.JobType,
+		)
+	}
+
+	body, err = h.request("/jobservice/pools")
+	if err != nil {
+		level.Error(h.logger).Log("msg", "Error retrieving jobservice pools", "err", err.Error())
+		return false
+	}
+	var pools poolsMetric
+	if err := json.Unmarshal(body, &pools); err != nil {
+		level.Error(h.logger).Log(err.Error())
+		return false
+	}
+
+	for _, p := range pools {
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["jobservice_pool_concurrency"].Desc, allMetrics["jobservice_pool_concurrency"].Type, p.Concurrency, p.WorkerPoolID, p.Host,
+		)
+
+		body, err := h.request("/jobservice/pools/" + url.PathEscape(p.WorkerPoolID) + "/workers")
+		if err != nil {
+			level.Error(h.logger).Log("msg", "Error retrieving jobservice workers for pool "+p.WorkerPoolID, "err", err.Error())
+			return false
+		}
+		var workers workersMetric
+		if err := json.Unmarshal(body, &workers); err != nil {
+			level.Error(h.logger).Log(err.Error())
+			return false
+		}
+
+		var busy, idle float64
+		for _, w := range workers {
+			if w.JobID != "" {
+				busy++
+			} else {
+				idle++
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["jobservice_pool_workers"].Desc, allMetrics["jobservice_pool_workers"].Type, busy, p.WorkerPoolID, p.Host, "busy",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["jobservice_pool_workers"].Desc, allMetrics["jobservice_pool_workers"].Type, idle, p.WorkerPoolID, p.Host, "idle",
+		)
+	}
+
+	reportLatency(start, "jobservice_latency", ch)
+	return true
+}
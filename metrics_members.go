@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// memberRoles maps Harbor role IDs to the role label values.
+var memberRoles = map[int64]string{
+	1: "admin",
+	4: "maintainer",
+	2: "developer",
+	3: "guest",
+	5: "limited_guest",
+}
+
+// memberEntityTypes maps Harbor member entity types to the entity_type
+// label values.
+var memberEntityTypes = map[string]string{
+	"u": "user",
+	"g": "group",
+}
+
+func (h *HarborExporter) collectMembersMetric(ch chan<- prometheus.Metric) bool {
+	start := time.Now()
+
+	type membersMetric []struct {
+		EntityName string `json:"entity_name"`
+		EntityType string `json:"entity_type"`
+		RoleID     int64  `json:"role_id"`
+		RoleName   string `json:"role_name"`
+	}
+	type memberKey struct {
+		role       string
+		entityType string
+	}
+
+	prData, err := h.loadProjects()
+	if err != nil {
+		return false
+	}
+
+	for pi := range prData {
+		var (
+			pp = &prData[pi]
+
+			projectName = pp.Name
+			projectID   = strconv.FormatInt(pp.ProjectID, 10)
+		)
+
+		var data membersMetric
+		err := h.requestAll("/projects/"+projectID+"/members", func(pageBody []byte) error {
+			var pageData membersMetric
+			if err := json.Unmarshal(pageBody, &pageData); err != nil {
+				return err
+			}
+			data = append(data, pageData...)
+
+			return nil
+		})
+		if err != nil {
+			level.Error(h.logger).Log("msg", "Error retrieving members of project "+projectName, "err", err.Error())
+			return false
+		}
+
+		counts := make(map[memberKey]float64)
+		for _, role := range memberRoles {
+			for _, entityType := range memberEntityTypes {
+				counts[memberKey{role, entityType}] = 0
+			}
+		}
+		for _, m := range data {
+			role, ok := memberRoles[m.RoleID]
+			if !ok {
+				role = m.RoleName
+			}
+			entityType, ok := memberEntityTypes[m.EntityType]
+			if !ok {
+				entityType = m.EntityType
+			}
+			counts[memberKey{role, entityType}]++
+
+			if h.membersInfo {
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["project_member_info"].Desc, allMetrics["project_member_info"].Type, 1, projectName, projectID, m.EntityName, entityType, role,
+				)
+			}
+		}
+		for k, v := range counts {
+			ch <- prometheus.MustNewConstMetric(
+				allMetrics["project_members"].Desc, allMetrics["project_members"].Type, v, projectName, projectID, k.role, k.entityType,
+			)
+		}
+	}
+
+	reportLatency(start, "members_latency", ch)
+	return true
+}
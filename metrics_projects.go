@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type projectSummary struct {
+	RepoCount         float64 `json:"repo_count"`
+	ChartCount        float64 `json:"chart_count"`
+	ProjectAdminCount float64 `json:"project_admin_count"`
+	MaintainerCount   float64 `json:"maintainer_count"`
+	DeveloperCount    float64 `json:"developer_count"`
+	GuestCount        float64 `json:"guest_count"`
+	LimitedGuestCount float64 `json:"limited_guest_count"`
+	Quota             *struct {
+		Hard struct {
+			Storage float64 `json:"storage"`
+		} `json:"hard"`
+		Used struct {
+			Storage float64 `json:"storage"`
+		} `json:"used"`
+	} `json:"quota"`
+}
+
+func (h *HarborExporter) collectProjectsMetric(ch chan<- prometheus.Metric) bool {
+	start := time.Now()
+
+	// System CVE allowlist. Harbor releases before 2.2 call it a whitelist.
+	body, err := h.request("/system/CVEAllowlist")
+	if err == errNotFound {
+		body, err = h.request("/system/CVEWhitelist")
+	}
+	if err == errNotFound {
+		level.Debug(h.logger).Log("msg", "System CVE allowlist is not available")
+	} else if err != nil {
+		level.Error(h.logger).Log("msg", "Error retrieving system CVE allowlist", "err", err.Error())
+		return false
+	} else {
+		var systemAllowlist cveAllowlist
+		if err := json.Unmarshal(body, &systemAllowlist); err != nil {
+			level.Error(h.logger).Log(err.Error())
+			return false
+		}
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["system_cve_allowlist_items"].Desc, allMetrics["system_cve_allowlist_items"].Type, float64(len(systemAllowlist.Items)),
+		)
+		if systemAllowlist.ExpiresAt > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				allMetrics["system_cve_allowlist_expiry_timestamp_seconds"].Desc, allMetrics["system_cve_allowlist_expiry_timestamp_seconds"].Type, float64(systemAllowlist.ExpiresAt),
+			)
+		}
+	}
+
+	prData, err := h.loadProjects()
+	if err != nil {
+		return false
+	}
+
+	for pi := range prData {
+		var (
+			pp = &prData[pi]
+			md = &pp.Metadata
+
+			projectName = pp.Name
+			projectID   = strconv.FormatInt(pp.ProjectID, 10)
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["project_public"].Desc, allMetrics["project_public"].Type, metadataFloat(md.Public), projectName, projectID,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["project_auto_scan"].Desc, allMetrics["project_auto_scan"].Type, metadataFloat(md.AutoScan), projectName, projectID,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["project_prevent_vul"].Desc, allMetrics["project_prevent_vul"].Type, metadataFloat(md.PreventVul), projectName, projectID, md.Severity,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["project_content_trust"].Desc, allMetrics["project_content_trust"].Type, metadataFloat(md.EnableContentTrust), projectName, projectID, "notary",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["project_content_trust"].Desc, allMetrics["project_content_trust"].Type, metadataFloat(md.EnableContentTrustCosign), projectName, projectID, "cosign",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["project_reuse_sys_cve_allowlist"].Desc, allMetrics["project_reuse_sys_cve_allowlist"].Type, metadataFloat(md.ReuseSysCVEAllowlist+md.ReuseSysCVEWhitelist), projectName, projectID,
+		)
+
+		// Project CVE allowlist, only Harbor 2.x lists it with the projects,
+		// as cve_whitelist before 2.2.
+		if h.isV2 {
+			allowlist := pp.CVEAllowlist
+			if pp.CVEWhitelist != nil {
+				allowlist = *pp.CVEWhitelist
+			}
+			ch <- prometheus.MustNewConstMetric(
+				allMetrics["project_cve_allowlist_items"].Desc, allMetrics["project_cve_allowlist_items"].Type, float64(len(allowlist.Items)), projectName, projectID,
+			)
+			if allowlist.ExpiresAt > 0 {
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["project_cve_allowlist_expiry_timestamp_seconds"].Desc, allMetrics["project_cve_allowlist_expiry_timestamp_seconds"].Type, float64(allowlist.ExpiresAt), projectName, projectID,
+				)
+			}
+		}
+
+		// Summary, one request per project for repository, chart and member
+		// counts and storage without walking the repositories.
+		if h.isV2 && h.projectsSummary {
+			body, err := h.request("/projects/" + projectID + "/summary")
+			if err != nil {
+				level.Error(h.logger).Log("msg", "Error retrieving summary of project "+projectName, "err", err.Error())
+				return false
+			}
+			var summary projectSummary
+			if err := json.Unmarshal(body, &summary); err != nil {
+				level.Error(h.logger).Log(err.Error())
+				return false
+			}
+
+			ch <- prometheus.MustNewConstMetric(
+				allMetrics["project_repo_count"].Desc, allMetrics["project_repo_count"].Type, summary.RepoCount, projectName, projectID,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				allMetrics["project_chart_count"].Desc, allMetrics["project_chart_count"].Type, summary.ChartCount, projectName, projectID,
+			)
+			// The quota is absent when quotas are disabled, a hard limit of
+			// -1 means unlimited.
+			if summary.Quota != nil {
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["project_storage_used_bytes"].Desc, allMetrics["project_storage_used_bytes"].Type, summary.Quota.Used.Storage, projectName, projectID,
+				)
+				if summary.Quota.Hard.Storage >= 0 {
+					ch <- prometheus.MustNewConstMetric(
+						allMetrics["project_storage_hard_bytes"].Desc, allMetrics["project_storage_hard_bytes"].Type, summary.Quota.Hard.Storage, projectName, projectID,
+					)
+				}
+			}
+			for role, count := range map[string]float64{
+				"admin":         summary.ProjectAdminCount,
+				"maintainer":    summary.MaintainerCount,
+				"developer":     summary.DeveloperCount,
+				"guest":         summary.GuestCount,
+				"limited_guest": summary.LimitedGuestCount,
+			} {
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["project_members_by_role"].Desc, allMetrics["project_members_by_role"].Type, count, projectName, projectID, role,
+				)
+			}
+		}
+
+		// Proxy-cache projects are backed by a registry endpoint.
+		var registryID string
+		if pp.RegistryID > 0 {
+			registryID = strconv.FormatInt(pp.RegistryID, 10)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["project_proxy_cache"].Desc, allMetrics["project_proxy_cache"].Type, float64(Btoi(pp.RegistryID > 0)), projectName, projectID, registryID,
+		)
+	}
+
+	reportLatency(start, "projects_latency", ch)
+	return true
+}
+
+// metadataFloat converts a project metadata value, which Harbor returns as
+// a "true" or "false" string, to a metric value.
+func metadataFloat(s string) float64 {
+	if s == "true" {
+		return 1
+	}
+	return 0
+}
@@ -9,27 +9,28 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+type quotaMetric []struct {
+	ID  float64 `json:"id"`
+	Ref struct {
+		ID        float64 `json:"id"`
+		Name      string  `json:"name"`
+		OwnerName string  `json:"owner_name"`
+	}
+	CreationTime time.Time
+	UpdateTime   time.Time
+	Hard         struct {
+		Count   float64 `json:"count"`
+		Storage float64 `json:"storage"`
+	}
+	Used struct {
+		Count   float64 `json:"count"`
+		Storage float64 `json:"storage"`
+	}
+}
+
 func (h *HarborExporter) collectQuotasMetric(ch chan<- prometheus.Metric) bool {
 	start := time.Now()
 
-	type quotaMetric []struct {
-		ID  float64 `json:"id"`
-		Ref struct {
-			ID        float64 `json:"id"`
-			Name      string  `json:"name"`
-			OwnerName string  `json:"owner_name"`
-		}
-		CreationTime time.Time
-		UpdateTime   time.Time
-		Hard         struct {
-			Count   float64 `json:"count"`
-			Storage float64 `json:"storage"`
-		}
-		Used struct {
-			Count   float64 `json:"count"`
-			Storage float64 `json:"storage"`
-		}
-	}
 	var data quotaMetric
 	err := h.requestAll("/quotas", func(pageBody []byte) error {
 		var pageData quotaMetric
@@ -45,6 +46,17 @@ func (h *HarborExporter) collectQuotasMetric(ch chan<- prometheus.Metric) bool {
 		return false
 	}
 
+	if h.isV2 {
+		h.collectProjectQuotasMetric(ch, data)
+	}
+
+	// Legacy metrics label the quota refs as repositories, in Harbor 2.x they
+	// are projects and the count quota does not exist anymore.
+	if h.isV2 && !h.quotasLegacy {
+		reportLatency(start, "quotas_latency", ch)
+		return true
+	}
+
 	for i := range data {
 		if data[i].Ref.Name == "" || data[i].Ref.ID == 0 {
 			level.Debug(h.logger).Log(data[i].Ref.ID, data[i].Ref.Name)
@@ -68,3 +80,42 @@ func (h *HarborExporter) collectQuotasMetric(ch chan<- prometheus.Metric) bool {
 	reportLatency(start, "quotas_latency", ch)
 	return true
 }
+
+// collectProjectQuotasMetric reports Harbor 2.x quotas, which are storage
+// quotas of projects. A hard limit of -1 means the project is unlimited.
+func (h *HarborExporter) collectProjectQuotasMetric(ch chan<- prometheus.Metric, data quotaMetric) {
+	var (
+		usedMI      = allMetrics["project_quota_used_bytes"]
+		hardMI      = allMetrics["project_quota_hard_bytes"]
+		unlimitedMI = allMetrics["project_quota_unlimited"]
+		ratioMI     = allMetrics["project_quota_usage_ratio"]
+	)
+
+	for i := range data {
+		if data[i].Ref.ID == 0 {
+			level.Debug(h.logger).Log("msg", "Skipping quota without project", "quota_id", data[i].ID)
+			continue
+		}
+		if data[i].Ref.Name == "" {
+			level.Debug(h.logger).Log("msg", "Quota has no project name", "project_id", data[i].Ref.ID)
+		}
+
+		var (
+			projectName = data[i].Ref.Name
+			projectID   = strconv.FormatFloat(data[i].Ref.ID, 'f', 0, 64)
+			used        = data[i].Used.Storage
+			hard        = data[i].Hard.Storage
+			unlimited   = hard < 0
+		)
+
+		ch <- prometheus.MustNewConstMetric(usedMI.Desc, usedMI.Type, used, projectName, projectID)
+		ch <- prometheus.MustNewConstMetric(unlimitedMI.Desc, unlimitedMI.Type, float64(Btoi(unlimited)), projectName, projectID)
+		if unlimited {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(hardMI.Desc, hardMI.Type, hard, projectName, projectID)
+		if hard > 0 {
+			ch <- prometheus.MustNewConstMetric(ratioMI.Desc, ratioMI.Type, used/hard, projectName, projectID)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func (h *HarborExporter) collectRegistriesMetric(ch chan<- prometheus.Metric) bool {
+	start := time.Now()
+
+	type registriesMetric []struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+		Type string `json:"type"`
+		URL  string `json:"url"`
+		// Extra fields omitted for maintainability: not relevant for current metrics
+	}
+
+	var data registriesMetric
+	err := h.requestAll("/registries", func(pageBody []byte) error {
+		var pageData registriesMetric
+		if err := json.Unmarshal(pageBody, &pageData); err != nil {
+			return err
+		}
+		data = append(data, pageData...)
+
+		return nil
+	})
+	if err != nil {
+		level.Error(h.logger).Log("msg", "Error retrieving registries", "err", err.Error())
+		return false
+	}
+
+	// Ping every endpoint at once, so that the collection takes as long as
+	// the slowest endpoint rather than all of them.
+	var (
+		ups       = make([]float64, len(data))
+		latencies = make([]float64, len(data))
+		wg        sync.WaitGroup
+	)
+	for i := range data {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Harbor pings the endpoint with the stored credential, so a
+			// rejected credential reports the endpoint as down as well.
+			pingStart := time.Now()
+			err := h.post("/registries/ping", map[string]int64{"id": data[i].ID})
+			latencies[i] = time.Since(pingStart).Seconds()
+			if err != nil {
+				level.Debug(h.logger).Log("msg", "Error pinging registry "+data[i].Name, "err", err.Error())
+				return
+			}
+			ups[i] = 1
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range data {
+		var (
+			registry    = &data[i]
+			up          = ups[i]
+			pingLatency = latencies[i]
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["registry_endpoint_up"].Desc, allMetrics["registry_endpoint_up"].Type, up, registry.Name, registry.Type, registry.URL,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["registry_endpoint_ping_latency"].Desc, allMetrics["registry_endpoint_ping_latency"].Type, pingLatency, registry.Name, registry.Type, registry.URL,
+		)
+	}
+
+	reportLatency(start, "registries_latency", ch)
+	return true
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-kit/kit/log/level"
@@ -11,23 +14,50 @@ import (
 
 func (h *HarborExporter) collectReplicationsMetric(ch chan<- prometheus.Metric) bool {
 	start := time.Now()
+	type policyRegistry struct {
+		Name string `json:"name"`
+	}
 	type policiesMetrics []struct {
 		ID      float64 `json:"id"`
 		Name    string  `json:"name"`
 		Enabled bool    `json:"enabled"`
 		Trigger struct {
-			Type string `json:"type"`
+			Type            string `json:"type"`
+			TriggerSettings struct {
+				Cron string `json:"cron"`
+			} `json:"trigger_settings"`
 		}
+		SrcRegistry   *policyRegistry `json:"src_registry"`
+		DestRegistry  *policyRegistry `json:"dest_registry"`
+		DestNamespace string          `json:"dest_namespace"`
+		Filters       []struct {
+			Type       string      `json:"type"`
+			Value      interface{} `json:"value"`
+			Decoration string      `json:"decoration"`
+		} `json:"filters"`
+		Deletion bool `json:"deletion"`
+		Override bool `json:"override"`
 		// Extra fields omitted for maintainability: not relevant for current metrics
 	}
 	type policyMetric []struct {
-		Status     string  `json:"status"`
-		Failed     float64 `json:"failed"`
-		Succeed    float64 `json:"succeed"`
-		InProgress float64 `json:"in_progress"`
-		Stopped    float64 `json:"stopped"`
+		ID         float64   `json:"id"`
+		Status     string    `json:"status"`
+		Failed     float64   `json:"failed"`
+		Succeed    float64   `json:"succeed"`
+		InProgress float64   `json:"in_progress"`
+		Stopped    float64   `json:"stopped"`
+		StartTime  time.Time `json:"start_time"`
+		EndTime    time.Time `json:"end_time"`
+		// Extra fields omitted for maintainability: not relevant for current metrics
+	}
+	type tasksMetricItem struct {
+		ResourceType string `json:"resource_type"`
+		SrcResource  string `json:"src_resource"`
+		DstResource  string `json:"dst_resource"`
+		Operation    string `json:"operation"`
 		// Extra fields omitted for maintainability: not relevant for current metrics
 	}
+	type tasksMetric []tasksMetricItem
 
 	var policiesData policiesMetrics
 	err := h.requestAll("/replication/policies", func(pageBody []byte) error {
@@ -44,50 +74,179 @@ func (h *HarborExporter) collectReplicationsMetric(ch chan<- prometheus.Metric)
 		return false
 	}
 
+	windowStart := start.Add(-h.replicationWindow)
+
 	for i := range policiesData {
-		if policiesData[i].Enabled == true {
-			policyID := strconv.FormatFloat(policiesData[i].ID, 'f', 0, 32)
-			policyName := policiesData[i].Name
-			triggerType := policiesData[i].Trigger.Type
+		policyID := strconv.FormatFloat(policiesData[i].ID, 'f', 0, 32)
+		policyName := policiesData[i].Name
+		triggerType := policiesData[i].Trigger.Type
+		enabled := strconv.FormatBool(policiesData[i].Enabled)
 
-			body, _ := h.request("/replication/executions?policy_id=" + policyID + "&page=1&page_size=2")
-			var data policyMetric
+		// Configuration, so that policy changes show up in the metric history.
+		var srcRegistry, destRegistry string
+		if policiesData[i].SrcRegistry != nil {
+			srcRegistry = policiesData[i].SrcRegistry.Name
+		}
+		if policiesData[i].DestRegistry != nil {
+			destRegistry = policiesData[i].DestRegistry.Name
+		}
+		var filters []string
+		for _, f := range policiesData[i].Filters {
+			op := "="
+			if f.Decoration == "excludes" {
+				op = "!="
+			}
+			filters = append(filters, f.Type+op+filterValue(f.Value))
+		}
+		sort.Strings(filters)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["replication_policy_info"].Desc, allMetrics["replication_policy_info"].Type, 1,
+			policyName, policyID, triggerType, enabled, srcRegistry, destRegistry, policiesData[i].DestNamespace, strings.Join(filters, ","),
+			strconv.FormatBool(policiesData[i].Deletion), strconv.FormatBool(policiesData[i].Override), policiesData[i].Trigger.TriggerSettings.Cron,
+		)
 
-			if err := json.Unmarshal(body, &data); err != nil {
-				level.Error(h.logger).Log("msg", "Error retrieving replication data for policy "+policyName+" (ID "+policyID+")", "err", err.Error())
-				return false
+		// Read the executions, newest first, until the sliding window is
+		// covered.
+		var data policyMetric
+		err := h.requestAll("/replication/executions?policy_id="+policyID+"&sort=-start_time", func(pageBody []byte) error {
+			var pageData policyMetric
+			if err := json.Unmarshal(pageBody, &pageData); err != nil {
+				return err
 			}
+			data = append(data, pageData...)
 
-			if len(data) == 0 {
-				level.Debug(h.logger).Log("msg", "Policy "+policyName+" (ID "+policyID+") has no executions yet")
-				continue
+			if len(pageData) == 0 || pageData[len(pageData)-1].StartTime.Before(windowStart) {
+				return errStopPaging
 			}
+			return nil
+		})
+		if err != nil {
+			level.Error(h.logger).Log("msg", "Error retrieving replication data for policy "+policyName+" (ID "+policyID+")", "err", err.Error())
+			return false
+		}
 
-			var j int = 0
-			if len(data) > 1 && data[j].Status == "InProgress" {
-				// Current is in progress: check previous replication execution
-				j = 1
+		// The last successful execution can be older than the window, ask
+		// for it separately unless the window has one.
+		var lastSuccess time.Time
+		for _, e := range data {
+			if e.Status == "Succeed" {
+				lastSuccess = e.EndTime
+				break
+			}
+		}
+		if lastSuccess.IsZero() && len(data) > 0 {
+			body, err := h.request("/replication/executions?policy_id=" + policyID + "&status=Succeed&sort=-start_time&page=1&page_size=1")
+			if err != nil {
+				level.Error(h.logger).Log("msg", "Error retrieving last successful replication for policy "+policyName+" (ID "+policyID+")", "err", err.Error())
+				return false
+			}
+			var succeeded policyMetric
+			if err := json.Unmarshal(body, &succeeded); err != nil {
+				level.Error(h.logger).Log(err.Error())
+				return false
+			}
+			if len(succeeded) > 0 {
+				lastSuccess = succeeded[0].EndTime
 			}
+		}
+
+		if len(data) == 0 {
+			level.Debug(h.logger).Log("msg", "Policy "+policyName+" (ID "+policyID+") has no executions yet")
+			continue
+		}
+
+		var j int = 0
+		if len(data) > 1 && data[j].Status == "InProgress" {
+			// Current is in progress: check previous replication execution
+			j = 1
+		}
+
+		var replStatus float64
+		replStatus = 0
+		if data[j].Status == "Succeed" {
+			replStatus = 1
+		}
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["replication_status"].Desc, allMetrics["replication_status"].Type, replStatus, policyName, triggerType, enabled,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["replication_tasks"].Desc, allMetrics["replication_tasks"].Type, data[j].Failed, policyName, triggerType, enabled, "failed",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["replication_tasks"].Desc, allMetrics["replication_tasks"].Type, data[j].Succeed, policyName, triggerType, enabled, "succeed",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["replication_tasks"].Desc, allMetrics["replication_tasks"].Type, data[j].InProgress, policyName, triggerType, enabled, "in_progress",
+		)
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["replication_tasks"].Desc, allMetrics["replication_tasks"].Type, data[j].Stopped, policyName, triggerType, enabled, "stopped",
+		)
 
-			var replStatus float64
-			replStatus = 0
-			if data[j].Status == "Succeed" {
-				replStatus = 1
+		// List the failed tasks of the latest execution, up to the configured cap.
+		if h.replicationFailedTasks > 0 && data[j].Failed > 0 {
+			executionID := strconv.FormatFloat(data[j].ID, 'f', 0, 64)
+
+			var tasks tasksMetric
+			err := h.requestAll("/replication/executions/"+executionID+"/tasks?status=Failed", func(pageBody []byte) error {
+				var pageData tasksMetric
+				if err := json.Unmarshal(pageBody, &pageData); err != nil {
+					return err
+				}
+				tasks = append(tasks, pageData...)
+
+				if len(tasks) >= h.replicationFailedTasks {
+					return errStopPaging
+				}
+				return nil
+			})
+			if err != nil {
+				level.Error(h.logger).Log("msg", "Error retrieving failed tasks of execution "+executionID+" for policy "+policyName, "err", err.Error())
+				return false
 			}
+			if len(tasks) > h.replicationFailedTasks {
+				level.Debug(h.logger).Log("msg", "Too many failed tasks for policy "+policyName+", only the first ones are reported", "limit", h.replicationFailedTasks)
+				tasks = tasks[:h.replicationFailedTasks]
+			}
+
+			// The same resource can fail more than once in an execution.
+			seen := make(map[tasksMetricItem]bool)
+			for _, t := range tasks {
+				if seen[t] {
+					continue
+				}
+				seen[t] = true
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["replication_failed_task"].Desc, allMetrics["replication_failed_task"].Type, 1, policyName, executionID, t.ResourceType, t.SrcResource, t.DstResource, t.Operation,
+				)
+			}
+		}
+
+		if !data[j].EndTime.IsZero() {
 			ch <- prometheus.MustNewConstMetric(
-				allMetrics["replication_status"].Desc, allMetrics["replication_status"].Type, replStatus, policyName, triggerType,
-			)
-			ch <- prometheus.MustNewConstMetric(
-				allMetrics["replication_tasks"].Desc, allMetrics["replication_tasks"].Type, data[j].Failed, policyName, triggerType, "failed",
-			)
-			ch <- prometheus.MustNewConstMetric(
-				allMetrics["replication_tasks"].Desc, allMetrics["replication_tasks"].Type, data[j].Succeed, policyName, triggerType, "succeed",
+				allMetrics["replication_last_duration_seconds"].Desc, allMetrics["replication_last_duration_seconds"].Type, data[j].EndTime.Sub(data[j].StartTime).Seconds(), policyName, triggerType, enabled,
 			)
+		}
+		if !lastSuccess.IsZero() {
 			ch <- prometheus.MustNewConstMetric(
-				allMetrics["replication_tasks"].Desc, allMetrics["replication_tasks"].Type, data[j].InProgress, policyName, triggerType, "in_progress",
+				allMetrics["replication_last_success_timestamp_seconds"].Desc, allMetrics["replication_last_success_timestamp_seconds"].Type, float64(lastSuccess.Unix()), policyName, triggerType, enabled,
 			)
+		}
+
+		executions := map[string]float64{"succeed": 0, "failed": 0, "stopped": 0, "in_progress": 0}
+		for _, e := range data {
+			if e.StartTime.Before(windowStart) {
+				continue
+			}
+			switch e.Status {
+			case "InProgress":
+				executions["in_progress"]++
+			default:
+				executions[strings.ToLower(e.Status)]++
+			}
+		}
+		for status, count := range executions {
 			ch <- prometheus.MustNewConstMetric(
-				allMetrics["replication_tasks"].Desc, allMetrics["replication_tasks"].Type, data[j].Stopped, policyName, triggerType, "stopped",
+				allMetrics["replication_executions"].Desc, allMetrics["replication_executions"].Type, count, policyName, triggerType, enabled, status,
 			)
 		}
 	}
@@ -95,3 +254,22 @@ func (h *HarborExporter) collectReplicationsMetric(ch chan<- prometheus.Metric)
 	reportLatency(start, "replication_latency", ch)
 	return true
 }
+
+// filterValue formats the value of a replication filter, which is a list of
+// labels for label filters and a pattern for the others.
+func filterValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			values = append(values, fmt.Sprint(item))
+		}
+		return strings.Join(values, "|")
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
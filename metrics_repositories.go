@@ -3,12 +3,106 @@ package main
 import (
 	"encoding/json"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// These are the repositories.listing enum values
+const (
+	repositoriesListingAuto       = "auto"
+	repositoriesListingGlobal     = "global"
+	repositoriesListingPerProject = "per-project"
+)
+
+func repositoriesListingValues() []string {
+	return []string{
+		repositoriesListingAuto,
+		repositoriesListingGlobal,
+		repositoriesListingPerProject,
+	}
+}
+
+// loadProjectRepositories lists the repositories of the given projects, keyed
+// by project ID. Harbor 2.1+ lists the repositories of all projects at once,
+// which saves one request per project.
+func (h *HarborExporter) loadProjectRepositories(projectsData projects) (map[int64]repositories, error) {
+	if h.isV2 && h.repositoriesListing != repositoriesListingPerProject && atomic.LoadInt32(&h.globalRepositoriesMissing) == 0 {
+		reposData, err := h.loadAllRepositories(projectsData)
+		if err == nil {
+			return reposData, nil
+		}
+		if err != errNotFound || h.repositoriesListing == repositoriesListingGlobal {
+			level.Error(h.logger).Log(err.Error())
+			return nil, err
+		}
+		level.Info(h.logger).Log("msg", "Global repository listing is not available, falling back to per-project listing")
+		atomic.StoreInt32(&h.globalRepositoriesMissing, 1)
+	}
+
+	reposData := make(map[int64]repositories, len(projectsData))
+	for i := range projectsData {
+		projectID := strconv.FormatInt(projectsData[i].ProjectID, 10)
+
+		var reqURL string
+		if h.isV2 {
+			reqURL = "/projects/" + projectsData[i].Name + "/repositories"
+		} else {
+			reqURL = "/repositories?project_id=" + projectID
+		}
+
+		var data repositories
+		err := h.requestAll(reqURL, func(pageBody []byte) error {
+			var pageData repositories
+			if err := json.Unmarshal(pageBody, &pageData); err != nil {
+				return err
+			}
+			data = append(data, pageData...)
+
+			return nil
+		})
+		if err != nil {
+			level.Error(h.logger).Log(err.Error())
+			return nil, err
+		}
+
+		reposData[projectsData[i].ProjectID] = data
+	}
+
+	return reposData, nil
+}
+
+// loadAllRepositories lists every repository with the global listing and
+// joins them to the given projects.
+func (h *HarborExporter) loadAllRepositories(projectsData projects) (map[int64]repositories, error) {
+	reposData := make(map[int64]repositories, len(projectsData))
+	for i := range projectsData {
+		reposData[projectsData[i].ProjectID] = nil
+	}
+
+	err := h.requestAll("/repositories", func(pageBody []byte) error {
+		var pageData repositories
+		if err := json.Unmarshal(pageBody, &pageData); err != nil {
+			return err
+		}
+		for _, repo := range pageData {
+			if _, ok := reposData[repo.ProjectID]; ok {
+				reposData[repo.ProjectID] = append(reposData[repo.ProjectID], repo)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reposData, nil
+}
+
 func (h *HarborExporter) collectRepositoriesMetric(ch chan<- prometheus.Metric) bool {
 	start := time.Now()
 	type projectsMetrics []struct {
@@ -40,16 +134,39 @@ func (h *HarborExporter) collectRepositoriesMetric(ch chan<- prometheus.Metric)
 			UpdateTime   time.Time `json:"update_time"`
 		}
 	}
-	type repositoriesMetricV2 []struct {
-		ID            float64   `json:"id"`
-		Name          string    `json:"name"`
-		ProjectID     float64   `json:"project_id"`
-		Description   string    `json:"description"`
-		PullCount     float64   `json:"pull_count"`
-		ArtifactCount float64   `json:"artifact_count"`
-		CreationTime  time.Time `json:"creation_time"`
-		UpdateTime    time.Time `json:"update_time"`
+
+	if h.isV2 {
+		prData, err := h.loadProjects()
+		if err != nil {
+			return false
+		}
+		reposData, err := h.loadProjectRepositories(prData)
+		if err != nil {
+			return false
+		}
+
+		for pi := range prData {
+			data := reposData[prData[pi].ProjectID]
+			for i := range data {
+				repoID := strconv.FormatInt(data[i].ID, 10)
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["repositories_pull_total"].Desc, allMetrics["repositories_pull_total"].Type, float64(data[i].PullCount), data[i].Name, repoID,
+				)
+				// ch <- prometheus.MustNewConstMetric(
+				// 	allMetrics["repositories_star_total"].Desc, allMetrics["repositories_star_total"].Type, data[i].Star_count, data[i].Name, repoId,
+				// )
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["repositories_tags_total"].Desc, allMetrics["repositories_tags_total"].Type, float64(data[i].ArtifactCount), data[i].Name, repoID,
+				)
+				h.collectRepositoryPulls(ch, prData[pi].Name, data[i].Name, float64(data[i].PullCount), data[i].CreationTime)
+			}
+		}
+
+		h.pullCounters.prune()
+		reportLatency(start, "repositories_latency", ch)
+		return true
 	}
+
 	var projectsData projectsMetrics
 	err := h.requestAll("/projects", func(pageBody []byte) error {
 		var pageData projectsMetrics
@@ -67,68 +184,108 @@ func (h *HarborExporter) collectRepositoriesMetric(ch chan<- prometheus.Metric)
 
 	for i := range projectsData {
 		projectID := strconv.FormatFloat(projectsData[i].ProjectID, 'f', 0, 32)
-		if h.isV2 {
-			var data repositoriesMetricV2
-			err := h.requestAll("/projects/"+projectsData[i].Name+"/repositories", func(pageBody []byte) error {
-				var pageData repositoriesMetricV2
-				if err := json.Unmarshal(pageBody, &pageData); err != nil {
-					return err
-				}
-
-				data = append(data, pageData...)
-
-				return nil
-			})
-			if err != nil {
-				level.Error(h.logger).Log(err.Error())
-				return false
+		projectName := projectsData[i].Name
+		var data repositoriesMetric
+		err := h.requestAll("/repositories?project_id="+projectID, func(pageBody []byte) error {
+			var pageData repositoriesMetric
+			if err := json.Unmarshal(pageBody, &pageData); err != nil {
+				return err
 			}
 
-			for i := range data {
-				repoID := strconv.FormatFloat(data[i].ID, 'f', 0, 32)
-				ch <- prometheus.MustNewConstMetric(
-					allMetrics["repositories_pull_total"].Desc, allMetrics["repositories_pull_total"].Type, data[i].PullCount, data[i].Name, repoID,
-				)
-				// ch <- prometheus.MustNewConstMetric(
-				// 	allMetrics["repositories_star_total"].Desc, allMetrics["repositories_star_total"].Type, data[i].Star_count, data[i].Name, repoId,
-				// )
-				ch <- prometheus.MustNewConstMetric(
-					allMetrics["repositories_tags_total"].Desc, allMetrics["repositories_tags_total"].Type, data[i].ArtifactCount, data[i].Name, repoID,
-				)
-			}
+			data = append(data, pageData...)
 
-		} else {
-			var data repositoriesMetric
-			err := h.requestAll("/repositories?project_id="+projectID, func(pageBody []byte) error {
-				var pageData repositoriesMetric
-				if err := json.Unmarshal(pageBody, &pageData); err != nil {
-					return err
-				}
-
-				data = append(data, pageData...)
-
-				return nil
-			})
-			if err != nil {
-				level.Error(h.logger).Log(err.Error())
-				return false
-			}
+			return nil
+		})
+		if err != nil {
+			level.Error(h.logger).Log(err.Error())
+			return false
+		}
 
-			for i := range data {
-				repoID := strconv.FormatFloat(data[i].ID, 'f', 0, 32)
-				ch <- prometheus.MustNewConstMetric(
-					allMetrics["repositories_pull_total"].Desc, allMetrics["repositories_pull_total"].Type, data[i].PullCount, data[i].Name, repoID,
-				)
-				ch <- prometheus.MustNewConstMetric(
-					allMetrics["repositories_star_total"].Desc, allMetrics["repositories_star_total"].Type, data[i].StarCount, data[i].Name, repoID,
-				)
-				ch <- prometheus.MustNewConstMetric(
-					allMetrics["repositories_tags_total"].Desc, allMetrics["repositories_tags_total"].Type, data[i].TagsCount, data[i].Name, repoID,
-				)
-			}
+		for i := range data {
+			repoID := strconv.FormatFloat(data[i].ID, 'f', 0, 32)
+			ch <- prometheus.MustNewConstMetric(
+				allMetrics["repositories_pull_total"].Desc, allMetrics["repositories_pull_total"].Type, data[i].PullCount, data[i].Name, repoID,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				allMetrics["repositories_star_total"].Desc, allMetrics["repositories_star_total"].Type, data[i].StarCount, data[i].Name, repoID,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				allMetrics["repositories_tags_total"].Desc, allMetrics["repositories_tags_total"].Type, data[i].TagsCount, data[i].Name, repoID,
+			)
+			h.collectRepositoryPulls(ch, projectName, data[i].Name, data[i].PullCount, data[i].CreationTime)
 		}
 	}
+	h.pullCounters.prune()
 
 	reportLatency(start, "repositories_latency", ch)
 	return true
 }
+
+// pullCounter follows the pull count of a repository across its deletion and
+// creation again, which starts the pull count of Harbor over from zero.
+type pullCounter struct {
+	creationTime time.Time
+	created      time.Time
+	base         float64
+	last         float64
+	seen         bool
+}
+
+// pullCounters holds the pull counters by project and repository name.
+type pullCounters struct {
+	mutex    sync.Mutex
+	counters map[string]*pullCounter
+}
+
+// observe records the pull count of a repository and returns the counter
+// value and the time it counts from. When the repository was created again,
+// the pulls of its previous life are carried over so the counter never goes
+// backwards, even if the new repository was pulled more often since.
+func (c *pullCounters) observe(key string, pullCount float64, creationTime time.Time) (float64, time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.counters == nil {
+		c.counters = make(map[string]*pullCounter)
+	}
+	counter := c.counters[key]
+	if counter == nil {
+		counter = &pullCounter{creationTime: creationTime, created: creationTime}
+		c.counters[key] = counter
+	}
+	if !counter.creationTime.Equal(creationTime) {
+		counter.base += counter.last
+		counter.creationTime = creationTime
+	}
+	counter.last = pullCount
+	counter.seen = true
+	return counter.base + pullCount, counter.created
+}
+
+// prune drops the counters of the repositories not seen since the previous
+// call, which no longer exist.
+func (c *pullCounters) prune() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, counter := range c.counters {
+		if !counter.seen {
+			delete(c.counters, key)
+			continue
+		}
+		counter.seen = false
+	}
+}
+
+// collectRepositoryPulls exports the pull count of a repository as a counter,
+// identified by the repository name so that it survives the repository being
+// deleted and created again.
+func (h *HarborExporter) collectRepositoryPulls(ch chan<- prometheus.Metric, projectName string, repoName string, pullCount float64, creationTime time.Time) {
+	total, created := h.pullCounters.observe(projectName+"/"+repoName, pullCount, creationTime)
+	ch <- prometheus.MustNewConstMetric(
+		allMetrics["repository_pulls_total"].Desc, allMetrics["repository_pulls_total"].Type, total, projectName, repoName,
+	)
+	if h.repositoriesPullsStart && !created.IsZero() {
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["repository_pulls_start_timestamp_seconds"].Desc, allMetrics["repository_pulls_start_timestamp_seconds"].Type, float64(created.Unix()), projectName, repoName,
+		)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// retentionHistoryMaxPages bounds how far back the execution history of a
+// retention policy is read when looking for its last run and dry-run.
+const retentionHistoryMaxPages = 10
+
+type retentionExecution struct {
+	ID        int64     `json:"id"`
+	Status    string    `json:"status"`
+	DryRun    bool      `json:"dry_run"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+func (h *HarborExporter) collectRetentionMetric(ch chan<- prometheus.Metric) bool {
+	// Do not load V1 API.
+	// ToDo: Implement V1 support.
+	if !h.isV2 {
+		return true
+	}
+
+	start := time.Now()
+
+	type retentionExecutions []retentionExecution
+	type retentionTasks []struct {
+		Repository string  `json:"repository"`
+		Total      float64 `json:"total"`
+		Retained   float64 `json:"retained"`
+	}
+
+	prData, err := h.loadProjects()
+	if err != nil {
+		return false
+	}
+
+	for pi := range prData {
+		var (
+			pp = &prData[pi]
+
+			projectName = pp.Name
+			projectID   = strconv.FormatInt(pp.ProjectID, 10)
+			retentionID = pp.Metadata.RetentionID
+		)
+		if retentionID == "" {
+			continue
+		}
+
+		// Find the last finished run and the last finished dry-run.
+		var (
+			lastRun    *retentionExecution
+			lastDryRun *retentionExecution
+			pages      int
+		)
+		err := h.requestAll("/retentions/"+retentionID+"/executions", func(pageBody []byte) error {
+			var pageData retentionExecutions
+			if err := json.Unmarshal(pageBody, &pageData); err != nil {
+				return err
+			}
+			for i := range pageData {
+				e := pageData[i]
+				switch e.Status {
+				case "Running", "InProgress", "Pending", "Scheduled":
+					continue
+				}
+				if e.DryRun && lastDryRun == nil {
+					lastDryRun = &e
+				}
+				if !e.DryRun && lastRun == nil {
+					lastRun = &e
+				}
+			}
+			pages++
+			if (lastRun != nil && lastDryRun != nil) || pages >= retentionHistoryMaxPages {
+				return errStopPaging
+			}
+			return nil
+		})
+		if err != nil {
+			level.Error(h.logger).Log("msg", "Error retrieving retention executions for project "+projectName, "err", err.Error())
+			return false
+		}
+
+		for _, e := range []*retentionExecution{lastRun, lastDryRun} {
+			if e == nil {
+				continue
+			}
+			dryRun := strconv.FormatBool(e.DryRun)
+
+			var status float64
+			if e.Status == "Success" || e.Status == "Succeed" {
+				status = 1
+			}
+			ch <- prometheus.MustNewConstMetric(
+				allMetrics["retention_status"].Desc, allMetrics["retention_status"].Type, status, projectName, projectID, dryRun,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				allMetrics["retention_timestamp_seconds"].Desc, allMetrics["retention_timestamp_seconds"].Type, float64(e.EndTime.Unix()), projectName, projectID, dryRun,
+			)
+
+			executionID := strconv.FormatInt(e.ID, 10)
+			var tasks retentionTasks
+			err := h.requestAll("/retentions/"+retentionID+"/executions/"+executionID+"/tasks", func(pageBody []byte) error {
+				var pageData retentionTasks
+				if err := json.Unmarshal(pageBody, &pageData); err != nil {
+					return err
+				}
+				tasks = append(tasks, pageData...)
+
+				return nil
+			})
+			if err != nil {
+				level.Error(h.logger).Log("msg", "Error retrieving retention tasks for project "+projectName, "err", err.Error())
+				return false
+			}
+
+			for _, t := range tasks {
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["retention_artifacts"].Desc, allMetrics["retention_artifacts"].Type, t.Retained, projectName, projectID, t.Repository, dryRun, "retained",
+				)
+				ch <- prometheus.MustNewConstMetric(
+					allMetrics["retention_artifacts"].Desc, allMetrics["retention_artifacts"].Type, t.Total-t.Retained, projectName, projectID, t.Repository, dryRun, "deleted",
+				)
+			}
+		}
+	}
+
+	reportLatency(start, "retention_latency", ch)
+	return true
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func (h *HarborExporter) collectRobotsMetric(ch chan<- prometheus.Metric) bool {
+	// The global robot accounts API is only available on Harbor 2.2+.
+	if !h.isV2 {
+		return true
+	}
+
+	start := time.Now()
+
+	type robotsMetric []struct {
+		ID        int64  `json:"id"`
+		Name      string `json:"name"`
+		Level     string `json:"level"`
+		Duration  int64  `json:"duration"`
+		Disable   bool   `json:"disable"`
+		ExpiresAt int64  `json:"expires_at"`
+	}
+
+	// Without a query, only system robots are listed. Project robots are
+	// listed project by project.
+	loadRobots := func(endpoint string) (robotsMetric, error) {
+		var data robotsMetric
+		err := h.requestAll(endpoint, func(pageBody []byte) error {
+			var pageData robotsMetric
+			if err := json.Unmarshal(pageBody, &pageData); err != nil {
+				return err
+			}
+			data = append(data, pageData...)
+
+			return nil
+		})
+		return data, err
+	}
+
+	data, err := loadRobots("/robots")
+	if err == errNotFound {
+		level.Debug(h.logger).Log("msg", "Robot accounts API is not available, it requires Harbor 2.2 or later")
+		return true
+	}
+	if err != nil {
+		level.Error(h.logger).Log("msg", "Error retrieving robot accounts", "err", err.Error())
+		return false
+	}
+
+	// Project of each robot account, empty for system robots.
+	robotProjects := make([]string, len(data))
+
+	prData, err := h.loadProjects()
+	if err != nil {
+		return false
+	}
+	for pi := range prData {
+		projectID := strconv.FormatInt(prData[pi].ProjectID, 10)
+		projectData, err := loadRobots("/robots?q=" + url.QueryEscape("Level=project,ProjectID="+projectID))
+		if err != nil {
+			level.Error(h.logger).Log("msg", "Error retrieving robot accounts of project "+prData[pi].Name, "err", err.Error())
+			return false
+		}
+		for range projectData {
+			robotProjects = append(robotProjects, prData[pi].Name)
+		}
+		data = append(data, projectData...)
+	}
+
+	neverExpiring := make(map[string]float64)
+	for i := range data {
+		var (
+			robot   = &data[i]
+			project = robotProjects[i]
+		)
+
+		if robot.Duration == -1 {
+			neverExpiring[robot.Level]++
+		} else if robot.ExpiresAt > 0 {
+			ch <- prometheus.MustNewConstMetric(
+				allMetrics["robot_expiry_timestamp_seconds"].Desc, allMetrics["robot_expiry_timestamp_seconds"].Type, float64(robot.ExpiresAt), robot.Name, robot.Level, project,
+			)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["robot_disabled"].Desc, allMetrics["robot_disabled"].Type, float64(Btoi(robot.Disable)), robot.Name, robot.Level, project,
+		)
+	}
+
+	for _, robotLevel := range []string{"system", "project"} {
+		ch <- prometheus.MustNewConstMetric(
+			allMetrics["robots_never_expiring"].Desc, allMetrics["robots_never_expiring"].Type, neverExpiring[robotLevel], robotLevel,
+		)
+	}
+
+	reportLatency(start, "robots_latency", ch)
+	return true
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// The exporter state is kept in these files under storage.path. The artifact
+// listings are the bulk of it and are kept apart, so that they can be
+// rewritten less often than the rest.
+const (
+	stateFileName     = "state.json"
+	artifactsFileName = "artifacts.json"
+)
+
+// exporterState is what the exporter keeps on disk across restarts: the
+// metrics of the last successful collection, the repository pull counters
+// and the cursors of the incremental collectors.
+type exporterState struct {
+	SnapshotTime  time.Time                   `json:"snapshot_time"`
+	Snapshot      []stateSample               `json:"snapshot"`
+	RollingCursor int64                       `json:"rolling_cursor,omitempty"`
+	AuditLogs     *stateAuditLogs             `json:"audit_logs,omitempty"`
+	PullCounters  map[string]statePullCounter `json:"pull_counters,omitempty"`
+}
+
+// stateSample is a metric of the snapshot, identified by its allMetrics key.
+type stateSample struct {
+	Metric string   `json:"metric"`
+	Labels []string `json:"labels,omitempty"`
+	Value  float64  `json:"value"`
+}
+
+type stateRepository struct {
+	UpdateTime    time.Time `json:"update_time"`
+	ArtifactCount int64     `json:"artifact_count"`
+	PullCount     int64     `json:"pull_count"`
+	ListTime      time.Time `json:"list_time"`
+	Artifacts     artifacts `json:"artifacts"`
+}
+
+type statePullCounter struct {
+	CreationTime time.Time `json:"creation_time"`
+	Created      time.Time `json:"created"`
+	Base         float64   `json:"base"`
+	Last         float64   `json:"last"`
+}
+
+type stateAuditLogs struct {
+	Tailing    bool                          `json:"tailing"`
+	LastID     int64                         `json:"last_id"`
+	Operations []stateAuditLogOperation      `json:"operations,omitempty"`
+	Users      map[string]map[string]float64 `json:"users,omitempty"`
+}
+
+type stateAuditLogOperation struct {
+	Project      string  `json:"project"`
+	Operation    string  `json:"operation"`
+	ResourceType string  `json:"resource_type"`
+	Value        float64 `json:"value"`
+}
+
+// loadState restores the state saved under storage.path by a previous run.
+// With cache.enabled, the restored snapshot is served for cache.duration so
+// that metrics are available before the first collection completes.
+func (h *HarborExporter) loadState() error {
+	var repositories map[int64]stateRepository
+	if err := readStateFile(filepath.Join(h.storagePath, artifactsFileName), &repositories); err != nil {
+		return err
+	}
+	c := &h.artifactCache
+	c.mutex.Lock()
+	c.repos = make(map[int64]*artifactCacheEntry, len(repositories))
+	for id, repo := range repositories {
+		c.repos[id] = &artifactCacheEntry{
+			updateTime:    repo.UpdateTime,
+			artifactCount: repo.ArtifactCount,
+			pullCount:     repo.PullCount,
+			listTime:      repo.ListTime,
+			artifacts:     repo.Artifacts,
+		}
+	}
+	c.mutex.Unlock()
+
+	var state exporterState
+	if err := readStateFile(filepath.Join(h.storagePath, stateFileName), &state); err != nil {
+		return err
+	}
+	h.stateMutex.Lock()
+	h.snapshotTime = state.SnapshotTime
+	h.snapshot = state.Snapshot
+	h.stateMutex.Unlock()
+
+	c.mutex.Lock()
+	c.cursor = state.RollingCursor
+	c.mutex.Unlock()
+
+	p := &h.pullCounters
+	p.mutex.Lock()
+	p.counters = make(map[string]*pullCounter, len(state.PullCounters))
+	for key, counter := range state.PullCounters {
+		p.counters[key] = &pullCounter{
+			creationTime: counter.CreationTime,
+			created:      counter.Created,
+			base:         counter.Base,
+			last:         counter.Last,
+		}
+	}
+	p.mutex.Unlock()
+
+	if state.AuditLogs != nil {
+		s := &h.auditLogs
+		s.mutex.Lock()
+		s.loaded = true
+		s.tailing = state.AuditLogs.Tailing
+		s.lastID = state.AuditLogs.LastID
+		s.operations = make(map[auditLogKey]float64, len(state.AuditLogs.Operations))
+		for _, op := range state.AuditLogs.Operations {
+			s.operations[auditLogKey{op.Project, op.Operation, op.ResourceType}] = op.Value
+		}
+		s.users = state.AuditLogs.Users
+		if s.users == nil {
+			s.users = make(map[string]map[string]float64)
+		}
+		s.mutex.Unlock()
+	}
+
+	if h.cacheEnabled {
+		h.collectMutex.Lock()
+		h.cache = h.cache[:0]
+		for _, sample := range state.Snapshot {
+			mi, ok := allMetrics[sample.Metric]
+			if !ok {
+				continue
+			}
+			metric, err := prometheus.NewConstMetric(mi.Desc, mi.Type, sample.Value, sample.Labels...)
+			if err != nil {
+				level.Debug(h.logger).Log("msg", "Skipping snapshot sample "+sample.Metric, "err", err.Error())
+				continue
+			}
+			h.cache = append(h.cache, metric)
+		}
+		h.lastCollectTime = time.Now()
+		h.collectMutex.Unlock()
+	}
+
+	level.Info(h.logger).Log("msg", "Restored state", "snapshot_time", state.SnapshotTime, "samples", len(state.Snapshot), "repositories", len(repositories))
+	return nil
+}
+
+// readStateFile unmarshals the given state file into v, a missing file
+// leaves v untouched.
+func readStateFile(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// saveState writes the current state under storage.path. The given metrics
+// replace the saved snapshot, nil keeps the previous one. The artifact
+// listings are rewritten at most every storage.interval.
+func (h *HarborExporter) saveState(metrics []prometheus.Metric) error {
+	h.stateMutex.Lock()
+	defer h.stateMutex.Unlock()
+	if err := os.MkdirAll(h.storagePath, 0700); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if now.Sub(h.artifactsSaveTime) >= h.storageInterval {
+		if err := h.saveArtifactListings(); err != nil {
+			return err
+		}
+		h.artifactsSaveTime = now
+	}
+
+	if metrics != nil {
+		h.snapshotTime = now
+		h.snapshot = snapshotSamples(metrics)
+	}
+	state := exporterState{
+		SnapshotTime: h.snapshotTime,
+		Snapshot:     h.snapshot,
+	}
+
+	c := &h.artifactCache
+	c.mutex.Lock()
+	state.RollingCursor = c.cursor
+	c.mutex.Unlock()
+
+	p := &h.pullCounters
+	p.mutex.Lock()
+	if len(p.counters) > 0 {
+		state.PullCounters = make(map[string]statePullCounter, len(p.counters))
+		for key, counter := range p.counters {
+			state.PullCounters[key] = statePullCounter{
+				CreationTime: counter.creationTime,
+				Created:      counter.created,
+				Base:         counter.base,
+				Last:         counter.last,
+			}
+		}
+	}
+	p.mutex.Unlock()
+
+	s := &h.auditLogs
+	s.mutex.Lock()
+	if s.loaded {
+		state.AuditLogs = &stateAuditLogs{Tailing: s.tailing, LastID: s.lastID, Users: s.users}
+		for k, v := range s.operations {
+			state.AuditLogs.Operations = append(state.AuditLogs.Operations, stateAuditLogOperation{k.project, k.operation, k.resourceType, v})
+		}
+	}
+	data, err := json.Marshal(state)
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeStateFile(filepath.Join(h.storagePath, stateFileName), data)
+}
+
+// saveArtifactListings writes the cached artifact listings under
+// storage.path.
+func (h *HarborExporter) saveArtifactListings() error {
+	c := &h.artifactCache
+	c.mutex.Lock()
+	repositories := make(map[int64]stateRepository, len(c.repos))
+	for id, entry := range c.repos {
+		repositories[id] = stateRepository{
+			UpdateTime:    entry.updateTime,
+			ArtifactCount: entry.artifactCount,
+			PullCount:     entry.pullCount,
+			ListTime:      entry.listTime,
+			Artifacts:     entry.artifacts,
+		}
+	}
+	data, err := json.Marshal(repositories)
+	c.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeStateFile(filepath.Join(h.storagePath, artifactsFileName), data)
+}
+
+// writeStateFile writes then renames so a crash never leaves a truncated
+// state behind.
+func writeStateFile(path string, data []byte) error {
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// snapshotSamples converts the collected metrics back to their allMetrics
+// key, label values and value.
+func snapshotSamples(metrics []prometheus.Metric) []stateSample {
+	keys := make(map[*prometheus.Desc]string, len(allMetrics))
+	for k, mi := range allMetrics {
+		keys[mi.Desc] = k
+	}
+
+	samples := make([]stateSample, 0, len(metrics))
+	for _, metric := range metrics {
+		key, ok := keys[metric.Desc()]
+		if !ok {
+			continue
+		}
+		var pb dto.Metric
+		if err := metric.Write(&pb); err != nil {
+			continue
+		}
+
+		var value float64
+		switch {
+		case pb.Gauge != nil:
+			value = pb.Gauge.GetValue()
+		case pb.Counter != nil:
+			value = pb.Counter.GetValue()
+		case pb.Untyped != nil:
+			value = pb.Untyped.GetValue()
+		default:
+			continue
+		}
+
+		// Label pairs are sorted by name, the label values of a const
+		// metric follow the order of its descriptor.
+		pairs := make(map[string]string, len(pb.Label))
+		for _, lp := range pb.Label {
+			pairs[lp.GetName()] = lp.GetValue()
+		}
+		labels := allMetrics[key].Labels
+		sample := stateSample{Metric: key, Value: value}
+		if len(labels) > 0 {
+			sample.Labels = make([]string, len(labels))
+			for i, name := range labels {
+				sample.Labels[i] = pairs[name]
+			}
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}